@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/pflag"
+
+	"github.com/ecordell/bndlr/pkg/image/manifest"
+)
+
+// annotationOptions are the flags that add manifest annotations and config labels, shared by push and build
+type annotationOptions struct {
+	annotations     []string
+	annotationsFile string
+	labels          []string
+}
+
+func (o *annotationOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringArrayVar(&o.annotations, "annotation", nil, "add a key=value annotation to the manifest, i.e. org.opencontainers.image.revision=<commit>")
+	flags.StringVar(&o.annotationsFile, "annotations-file", "", "yaml or json file of manifest annotations. --annotation takes precedence")
+	flags.StringArrayVar(&o.labels, "label", nil, "add a key=value label to the image config")
+}
+
+// options returns the options that add the annotations to the manifest and the labels to the config of a built image
+func (o *annotationOptions) options() ([]manifest.ManifestOption, []manifest.ConfigOption, error) {
+	annotations := map[string]string{}
+	if o.annotationsFile != "" {
+		data, err := ioutil.ReadFile(o.annotationsFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := yaml.Unmarshal(data, &annotations); err != nil {
+			return nil, nil, fmt.Errorf("invalid annotations file %s: %v", o.annotationsFile, err)
+		}
+	}
+	if err := parseKeyValues(o.annotations, annotations); err != nil {
+		return nil, nil, fmt.Errorf("invalid annotation: %v", err)
+	}
+	labels := map[string]string{}
+	if err := parseKeyValues(o.labels, labels); err != nil {
+		return nil, nil, fmt.Errorf("invalid label: %v", err)
+	}
+
+	return []manifest.ManifestOption{manifest.WithAnnotations(annotations)}, []manifest.ConfigOption{manifest.WithLabels(labels)}, nil
+}
+
+// parseKeyValues adds each key=value pair to m, the value may be empty and later pairs replace earlier ones with the same key
+func parseKeyValues(pairs []string, m map[string]string) error {
+	for _, pair := range pairs {
+		i := strings.Index(pair, "=")
+		if i <= 0 {
+			return fmt.Errorf("%q should be key=value", pair)
+		}
+		m[pair[:i]] = pair[i+1:]
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/containerd/containerd/content"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/ecordell/bndlr/pkg/registry/common"
+	"github.com/ecordell/bndlr/pkg/registry/memory"
+)
+
+func TestParseKeyValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		pairs   []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "none", want: map[string]string{}},
+		{name: "pairs", pairs: []string{"a=1", "b=2"}, want: map[string]string{"a": "1", "b": "2"}},
+		{name: "value with =", pairs: []string{"a=b=c"}, want: map[string]string{"a": "b=c"}},
+		{name: "empty value", pairs: []string{"a="}, want: map[string]string{"a": ""}},
+		{name: "duplicate keys", pairs: []string{"a=1", "a=2"}, want: map[string]string{"a": "2"}},
+		{name: "empty key", pairs: []string{"=1"}, wantErr: true},
+		{name: "no =", pairs: []string{"a"}, wantErr: true},
+		{name: "empty", pairs: []string{""}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]string{}
+			err := parseKeyValues(tt.pairs, got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAnnotationOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bndlr-annotations-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "annotations.yaml")
+	if err := ioutil.WriteFile(file, []byte("org.opencontainers.image.source: https://example.com/bundle\norg.opencontainers.image.revision: from-file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := ioutil.WriteFile(invalid, []byte("- not\n- a map\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		opts            annotationOptions
+		wantAnnotations map[string]string
+		wantLabels      map[string]string
+		wantErr         bool
+	}{
+		{name: "none"},
+		{
+			name:            "file",
+			opts:            annotationOptions{annotationsFile: file},
+			wantAnnotations: map[string]string{"org.opencontainers.image.source": "https://example.com/bundle", "org.opencontainers.image.revision": "from-file"},
+		},
+		{
+			name: "flags override the file",
+			opts: annotationOptions{
+				annotationsFile: file,
+				annotations:     []string{"org.opencontainers.image.revision=from-flag", "example.com/team=olm"},
+			},
+			wantAnnotations: map[string]string{
+				"org.opencontainers.image.source":   "https://example.com/bundle",
+				"org.opencontainers.image.revision": "from-flag",
+				"example.com/team":                  "olm",
+			},
+		},
+		{
+			name:       "labels",
+			opts:       annotationOptions{labels: []string{"maintainer=olm", "version=0.9.2"}},
+			wantLabels: map[string]string{"maintainer": "olm", "version": "0.9.2"},
+		},
+		{name: "missing file", opts: annotationOptions{annotationsFile: filepath.Join(dir, "missing.yaml")}, wantErr: true},
+		{name: "invalid file", opts: annotationOptions{annotationsFile: invalid}, wantErr: true},
+		{name: "invalid annotation", opts: annotationOptions{annotations: []string{"revision"}}, wantErr: true},
+		{name: "invalid label", opts: annotationOptions{labels: []string{"=olm"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifestOptions, configOptions, err := tt.opts.options()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			// build the sample bundle with the options to check where they end up
+			s := memory.NewMemoryStore()
+			img, err := common.BuildDirectory(context.Background(), "example.com/bundle:v1", s, "../manifests",
+				common.WithManifestOptions(manifestOptions...), common.WithConfigOptions(configOptions...))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var m ocispec.Manifest
+			readJSON(t, s, img.Manifest, &m)
+			if !reflect.DeepEqual(m.Annotations, tt.wantAnnotations) {
+				t.Errorf("expected manifest annotations %v, got %v", tt.wantAnnotations, m.Annotations)
+			}
+			var config ocispec.Image
+			readJSON(t, s, img.Config, &config)
+			if !reflect.DeepEqual(config.Config.Labels, tt.wantLabels) {
+				t.Errorf("expected config labels %v, got %v", tt.wantLabels, config.Config.Labels)
+			}
+		})
+	}
+}
+
+func readJSON(t *testing.T, s *memory.MemoryStore, desc ocispec.Descriptor, v interface{}) {
+	t.Helper()
+	ra, err := s.ReaderAt(context.Background(), desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ra.Close()
+	if err := json.NewDecoder(content.NewReader(ra)).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/ecordell/bndlr/pkg/bundle"
+	"github.com/ecordell/bndlr/pkg/image/layer"
+	"github.com/ecordell/bndlr/pkg/image/manifest"
+	"github.com/ecordell/bndlr/pkg/registry/common"
+	"github.com/ecordell/bndlr/pkg/registry/ocilayout"
+	"github.com/ecordell/bndlr/pkg/signals"
+)
+
+const ociOutputPrefix = "oci:"
+
+type buildOptions struct {
+	output string
+
+	format       string
+	prefix       string
+	layers       []string
+	excludes     []string
+	includes     []string
+	reproducible bool
+	bundle       bundleOptions
+	annotations  annotationOptions
+
+	debug bool
+}
+
+var buildOpts buildOptions
+
+// buildCmd represents the build command
+var buildCmd = &cobra.Command{
+	Use:   "build <dir>",
+	Short: "Build a bundle image into an OCI image layout",
+	Long: `Build creates a bundle image from a directory of manifests and writes it into
+an OCI image layout on disk, without contacting any registry.
+
+The output is given as oci:<dir>[:<tag>]. The tag defaults to latest.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := signals.Context()
+
+		if len(args) < 1 {
+			return fmt.Errorf("should be called with one arg: dir")
+		}
+		dir := args[0]
+
+		if buildOpts.debug {
+			logrus.SetLevel(logrus.DebugLevel)
+		}
+
+		format, err := manifest.ParseFormat(buildOpts.format)
+		if err != nil {
+			return err
+		}
+
+		layoutDir, tag, err := parseOCIOutput(buildOpts.output)
+		if err != nil {
+			return err
+		}
+
+		store, err := ocilayout.NewOCILayoutStore(layoutDir)
+		if err != nil {
+			return err
+		}
+		defer closeStore(store)
+
+		selection := []layer.LayerOption{layer.WithExclude(buildOpts.excludes...), layer.WithInclude(buildOpts.includes...)}
+		files, err := layer.ListDirectory(dir, selection...)
+		if err != nil {
+			return err
+		}
+
+		metadata, prefix, err := buildOpts.bundle.metadata(dir, buildOpts.prefix, bundle.WithFiles(files))
+		if err != nil {
+			return err
+		}
+
+		layers, err := layerSpecs(buildOpts.layers)
+		if err != nil {
+			return err
+		}
+
+		manifestOpts, configOpts, err := buildOpts.annotations.options()
+		if err != nil {
+			return err
+		}
+
+		image, err := common.BuildDirectory(ctx, tag, store, dir,
+			common.WithFormat(format),
+			common.WithReproducible(buildOpts.reproducible),
+			common.WithLayerOptions(append(selection, layer.WithPrefix(prefix))...),
+			common.WithBundleMetadata(metadata),
+			common.WithLayers(layers...),
+			common.WithManifestOptions(manifestOpts...),
+			common.WithConfigOptions(configOpts...),
+		)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("built %s:%s with digest %s\n", layoutDir, tag, image.Manifest.Digest.String())
+		return nil
+	},
+}
+
+// parseOCIOutput splits an output of the form oci:<dir>[:<tag>] into a directory and tag
+func parseOCIOutput(output string) (string, string, error) {
+	if !strings.HasPrefix(output, ociOutputPrefix) {
+		return "", "", fmt.Errorf("output %q not supported, expected %s<dir>[:<tag>]", output, ociOutputPrefix)
+	}
+	dir := strings.TrimPrefix(output, ociOutputPrefix)
+	tag := "latest"
+	if i := strings.LastIndex(dir, ":"); i >= 0 {
+		dir, tag = dir[:i], dir[i+1:]
+	}
+	if dir == "" || tag == "" {
+		return "", "", fmt.Errorf("output %q must name a directory and tag", output)
+	}
+	return dir, tag, nil
+}
+
+// layerSpecs parses the --layer flags
+func layerSpecs(values []string) ([]common.LayerSpec, error) {
+	var specs []common.LayerSpec
+	for _, v := range values {
+		spec, err := common.ParseLayerSpec(v)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+func init() {
+	rootCmd.AddCommand(buildCmd)
+	buildCmd.Flags().StringVarP(&buildOpts.output, "output", "o", "", "where to write the image. Options: oci:<dir>[:<tag>]")
+	buildCmd.Flags().BoolVarP(&buildOpts.debug, "debug", "d", false, "enable debug logging")
+	buildCmd.Flags().StringVar(&buildOpts.format, "format", string(manifest.DockerFormat), "image media types. Options: docker, oci")
+	buildCmd.Flags().StringVar(&buildOpts.prefix, "prefix", "", "directory to store files under in the layer, i.e. manifests/")
+	buildCmd.Flags().StringArrayVar(&buildOpts.layers, "layer", nil, "put files matching path[:mediaType], a path or glob relative to the directory, into a layer of their own. repeat for more layers, remaining files go into a last layer")
+	buildCmd.Flags().StringArrayVar(&buildOpts.excludes, "exclude", nil, "leave files matching a gitignore pattern out of the image, after the patterns in the directory's .dlvrignore")
+	buildCmd.Flags().StringArrayVar(&buildOpts.includes, "include", nil, "only put files matching a gitignore pattern into the image. excluded files are still left out")
+	buildOpts.bundle.addFlags(buildCmd.Flags())
+	buildOpts.annotations.addFlags(buildCmd.Flags())
+	buildCmd.Flags().BoolVar(&buildOpts.reproducible, "reproducible", true, "normalize file metadata and timestamps so identical inputs produce identical digests. timestamps are taken from SOURCE_DATE_EPOCH")
+	_ = buildCmd.MarkFlagRequired("output")
+}
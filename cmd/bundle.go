@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"github.com/spf13/pflag"
+
+	"github.com/ecordell/bndlr/pkg/bundle"
+)
+
+// bundleOptions are the flags that control OLM bundle metadata generation, shared by push and build
+type bundleOptions struct {
+	enabled        bool
+	pkg            string
+	channels       []string
+	defaultChannel string
+}
+
+func (o *bundleOptions) addFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.enabled, "bundle", false, "generate OLM bundle metadata. implied by --package, --channels and --default-channel")
+	flags.StringVar(&o.pkg, "package", "", "bundle package name. inferred from the ClusterServiceVersion if unset")
+	flags.StringSliceVar(&o.channels, "channels", nil, "bundle channels. inferred from the ClusterServiceVersion maturity if unset")
+	flags.StringVar(&o.defaultChannel, "default-channel", "", "bundle default channel. defaults to the first channel")
+}
+
+// metadata returns the bundle metadata for a manifests directory, or nil if bundle metadata is not requested
+// manifests are stored under the prefix in the image, which defaults to `manifests/` for bundles
+func (o *bundleOptions) metadata(dir, prefix string, opts ...bundle.LoadOption) (*bundle.Metadata, string, error) {
+	if !o.enabled && o.pkg == "" && len(o.channels) == 0 && o.defaultChannel == "" {
+		return nil, prefix, nil
+	}
+	if prefix == "" {
+		prefix = bundle.ManifestsDir
+	}
+
+	m := &bundle.Metadata{}
+	if o.pkg == "" || len(o.channels) == 0 {
+		inferred, err := bundle.InferMetadata(dir, opts...)
+		if err != nil {
+			return nil, "", err
+		}
+		m = inferred
+	}
+	if o.pkg != "" {
+		m.Package = o.pkg
+	}
+	if len(o.channels) > 0 {
+		m.Channels = o.channels
+		m.DefaultChannel = o.channels[0]
+	}
+	if o.defaultChannel != "" {
+		m.DefaultChannel = o.defaultChannel
+	}
+	m.Manifests = prefix
+	return m, prefix, nil
+}
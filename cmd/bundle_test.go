@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ecordell/bndlr/pkg/bundle"
+)
+
+func TestBundleMetadata(t *testing.T) {
+	// the sample bundle has a single CSV, etcdoperator.v0.9.2, with maturity alpha
+	const dir = "../manifests"
+
+	tests := []struct {
+		name       string
+		opts       bundleOptions
+		prefix     string
+		want       *bundle.Metadata
+		wantPrefix string
+	}{
+		{
+			name:       "not a bundle",
+			prefix:     "deploy/",
+			wantPrefix: "deploy/",
+		},
+		{
+			name:       "inferred",
+			opts:       bundleOptions{enabled: true},
+			want:       &bundle.Metadata{Package: "etcdoperator", Channels: []string{"alpha"}, DefaultChannel: "alpha", Manifests: bundle.ManifestsDir},
+			wantPrefix: bundle.ManifestsDir,
+		},
+		{
+			name:       "default channel falls back to the first channel",
+			opts:       bundleOptions{channels: []string{"stable", "beta"}},
+			want:       &bundle.Metadata{Package: "etcdoperator", Channels: []string{"stable", "beta"}, DefaultChannel: "stable", Manifests: bundle.ManifestsDir},
+			wantPrefix: bundle.ManifestsDir,
+		},
+		{
+			name:       "every flag set",
+			opts:       bundleOptions{pkg: "etcd", channels: []string{"stable", "beta"}, defaultChannel: "beta"},
+			want:       &bundle.Metadata{Package: "etcd", Channels: []string{"stable", "beta"}, DefaultChannel: "beta", Manifests: bundle.ManifestsDir},
+			wantPrefix: bundle.ManifestsDir,
+		},
+		{
+			name:       "default channel overrides the inferred channel",
+			opts:       bundleOptions{pkg: "etcd", defaultChannel: "stable"},
+			want:       &bundle.Metadata{Package: "etcd", Channels: []string{"alpha"}, DefaultChannel: "stable", Manifests: bundle.ManifestsDir},
+			wantPrefix: bundle.ManifestsDir,
+		},
+		{
+			name:       "manifests prefix",
+			opts:       bundleOptions{enabled: true},
+			prefix:     "deploy/",
+			want:       &bundle.Metadata{Package: "etcdoperator", Channels: []string{"alpha"}, DefaultChannel: "alpha", Manifests: "deploy/"},
+			wantPrefix: "deploy/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, prefix, err := tt.opts.metadata(dir, tt.prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+			if prefix != tt.wantPrefix {
+				t.Errorf("expected prefix %q, got %q", tt.wantPrefix, prefix)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/containerd/containerd/remotes"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/ecordell/bndlr/pkg/registry"
+	"github.com/ecordell/bndlr/pkg/registry/common"
+	"github.com/ecordell/bndlr/pkg/signals"
+)
+
+// credentialOptions are the auth flags for one side of a copy
+type credentialOptions struct {
+	configs  []string
+	username string
+	password string
+}
+
+func (o *credentialOptions) addFlags(flags *pflag.FlagSet, side, description string) {
+	flags.StringArrayVar(&o.configs, side+"-config", nil, "auth config path for the "+description+". defaults to $REGISTRY_AUTH_FILE, or podman's auth.json and ~/.docker/config.json")
+	flags.StringVar(&o.username, side+"-username", "", "username for the "+description)
+	flags.StringVar(&o.password, side+"-password", "", "password for the "+description)
+}
+
+func (o *credentialOptions) resolver(opts []registry.ResolverOption) (remotes.Resolver, error) {
+	return registry.NewResolver(o.username, o.password, o.configs, opts...)
+}
+
+type copyOptions struct {
+	src credentialOptions
+	dst credentialOptions
+
+	storeType string
+	storeDir  string
+	keepStore bool
+
+	publish  publishOptions
+	registry registryOptions
+
+	debug bool
+}
+
+var copyOpts copyOptions
+
+// copyCmd represents the copy command
+var copyCmd = &cobra.Command{
+	Use:   "copy <src-ref> <dst-ref> [dst-ref...]",
+	Short: "Copy a bundle image between registries without rebuilding it",
+	Long: `Copy pulls an image into the configured storage and pushes its manifest, config
+and layers to each destination as they are, so the image keeps its digest.
+
+Destinations on the same registry as the source mount blobs from the source
+repository instead of uploading them. The source and destination can use
+different credentials with the --src-* and --dst-* flags.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, err := copyOpts.registry.withRetryPolicy(signals.Context())
+		if err != nil {
+			return err
+		}
+
+		if len(args) < 2 {
+			return fmt.Errorf("should be called with at least two args: src-ref dst-ref")
+		}
+		src, dsts := args[0], args[1:]
+
+		if copyOpts.debug {
+			logrus.SetLevel(logrus.DebugLevel)
+		}
+
+		publishOpts, err := copyOpts.publish.pushOptions()
+		if err != nil {
+			return err
+		}
+		resolverOpts, err := copyOpts.registry.resolverOptions()
+		if err != nil {
+			return err
+		}
+		from, err := copyOpts.src.resolver(resolverOpts)
+		if err != nil {
+			return err
+		}
+		to, err := copyOpts.dst.resolver(resolverOpts)
+		if err != nil {
+			return err
+		}
+		store, err := newStore(copyOpts.storeType, copyOpts.storeDir, copyOpts.keepStore)
+		if err != nil {
+			return err
+		}
+		defer closeStore(store)
+
+		image, err := common.PullForCopy(ctx, store, from, src)
+		if err != nil {
+			return err
+		}
+		return copyOpts.publish.publish(ctx, store, to, image, dsts, publishOpts)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(copyCmd)
+	copyOpts.src.addFlags(copyCmd.Flags(), "src", "source registry")
+	copyOpts.dst.addFlags(copyCmd.Flags(), "dst", "destination registry")
+	copyOpts.registry.addFlags(copyCmd.Flags())
+	copyOpts.publish.addFlags(copyCmd.Flags())
+	copyCmd.Flags().BoolVarP(&copyOpts.debug, "debug", "d", false, "enable debug logging")
+	copyCmd.Flags().StringVarP(&copyOpts.storeType, "storage", "s", string(TmpFileStoreType), "configure storage. Options: memory, tmp, file")
+	copyCmd.Flags().StringVar(&copyOpts.storeDir, "storagePath", "", "configure storage location. only valid for storage type file")
+	copyCmd.Flags().BoolVar(&copyOpts.keepStore, "keep-store", false, "keep the temporary directory of storage type tmp, to debug what was written")
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/ecordell/bndlr/pkg/registry"
+	"github.com/ecordell/bndlr/pkg/registry/common"
+	"github.com/ecordell/bndlr/pkg/registry/memory"
+	"github.com/ecordell/bndlr/pkg/signals"
+)
+
+type inspectOptions struct {
+	// auth
+	configs  []string
+	username string
+	password string
+
+	output string
+
+	registry registryOptions
+
+	debug bool
+}
+
+var inspectOpts inspectOptions
+
+// inspectCmd represents the inspect command
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <ref>",
+	Short: "Print the manifest, config, layers and bundle metadata of a remote image",
+	Long: `Inspect fetches an image and prints its manifest, its config (including labels
+and history), the media type, size and digest of each layer, the files in each
+layer, and any OLM bundle metadata.
+
+Use --output json for output that scripts can consume.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, err := inspectOpts.registry.withRetryPolicy(signals.Context())
+		if err != nil {
+			return err
+		}
+
+		if len(args) < 1 {
+			return fmt.Errorf("should be called with one arg: ref")
+		}
+		ref := args[0]
+
+		if inspectOpts.debug {
+			logrus.SetLevel(logrus.DebugLevel)
+		}
+		if inspectOpts.output != "text" && inspectOpts.output != "json" {
+			return fmt.Errorf("output %s not supported. Options: text, json", inspectOpts.output)
+		}
+
+		resolverOpts, err := inspectOpts.registry.resolverOptions()
+		if err != nil {
+			return err
+		}
+		resolver, err := registry.NewResolver(inspectOpts.username, inspectOpts.password, inspectOpts.configs, resolverOpts...)
+		if err != nil {
+			return err
+		}
+		store := memory.NewMemoryStore()
+		defer closeStore(store)
+
+		info, err := common.InspectImage(ctx, ref, store, resolver)
+		if err != nil {
+			return err
+		}
+
+		if inspectOpts.output == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(info)
+		}
+		return printImageInfo(os.Stdout, info)
+	},
+}
+
+func printImageInfo(out io.Writer, info *common.ImageInfo) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Ref:\t%s\n", info.Ref)
+	fmt.Fprintf(w, "Digest:\t%s\n", info.Descriptor.Digest)
+	fmt.Fprintf(w, "MediaType:\t%s\n", info.Descriptor.MediaType)
+	fmt.Fprintf(w, "Size:\t%d\n", info.Descriptor.Size)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	pretty, err := json.MarshalIndent(info.Manifest, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\nManifest:\n  %s\n", pretty)
+
+	config := info.Config
+	fmt.Fprintf(out, "\nConfig:\n")
+	if config.Created != nil {
+		fmt.Fprintf(out, "  Created:  %s\n", config.Created)
+	}
+	fmt.Fprintf(out, "  Platform: %s/%s\n", config.OS, config.Architecture)
+	if len(config.Config.Labels) > 0 {
+		fmt.Fprintf(out, "  Labels:\n")
+		printMap(out, "    ", config.Config.Labels)
+	}
+	if len(config.History) > 0 {
+		fmt.Fprintf(out, "  History:\n")
+		for _, h := range config.History {
+			created := ""
+			if h.Created != nil {
+				created = h.Created.String()
+			}
+			fmt.Fprintf(out, "    - %s %s\n", created, strings.TrimSpace(h.CreatedBy+" "+h.Comment))
+		}
+	}
+
+	fmt.Fprintf(out, "\nLayers:\n")
+	for i, l := range info.Layers {
+		fmt.Fprintf(out, "  [%d] %s %s %d bytes\n", i, l.Digest, l.MediaType, l.Size)
+		for _, f := range l.Files {
+			fmt.Fprintf(out, "      %s\n", f)
+		}
+	}
+
+	if len(info.Bundle) > 0 {
+		fmt.Fprintf(out, "\nBundle:\n")
+		printMap(out, "  ", info.Bundle)
+	}
+	return nil
+}
+
+// printMap prints a map sorted by key
+func printMap(out io.Writer, indent string, m map[string]string) {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(out, "%s%s=%s\n", indent, k, m[k])
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+	inspectCmd.Flags().StringArrayVarP(&inspectOpts.configs, "config", "c", nil, "auth config path. defaults to $REGISTRY_AUTH_FILE, or podman's auth.json and ~/.docker/config.json")
+	inspectCmd.Flags().StringVarP(&inspectOpts.username, "username", "u", "", "username")
+	inspectCmd.Flags().StringVarP(&inspectOpts.password, "password", "p", "", "password")
+	inspectOpts.registry.addFlags(inspectCmd.Flags())
+	inspectCmd.Flags().BoolVarP(&inspectOpts.debug, "debug", "d", false, "enable debug logging")
+	inspectCmd.Flags().StringVarP(&inspectOpts.output, "output", "o", "text", "output format. Options: text, json")
+}
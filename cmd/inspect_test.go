@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/images"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/ecordell/bndlr/pkg/registry/common"
+)
+
+func TestPrintImageInfo(t *testing.T) {
+	created := time.Unix(0, 0).UTC()
+	info := &common.ImageInfo{
+		Ref: "example.com/bundle:v1",
+		Descriptor: ocispec.Descriptor{
+			MediaType: images.MediaTypeDockerSchema2Manifest,
+			Digest:    digest.FromString("manifest"),
+			Size:      583,
+		},
+		Manifest: []byte(`{"schemaVersion":2}`),
+		Config: ocispec.Image{
+			Created:      &created,
+			OS:           "linux",
+			Architecture: "amd64",
+			Config:       ocispec.ImageConfig{Labels: map[string]string{"version": "0.1.0", "maintainer": "olm"}},
+			History:      []ocispec.History{{Created: &created, CreatedBy: "bndlr"}},
+		},
+		Layers: []common.LayerInfo{{
+			Descriptor: ocispec.Descriptor{
+				MediaType: images.MediaTypeDockerSchema2LayerGzip,
+				Digest:    digest.FromString("layer"),
+				Size:      264,
+			},
+			Files: []string{"crds/", "crds/examples.example.com.crd.yaml"},
+		}},
+		Bundle: map[string]string{"operators.operatorframework.io.bundle.package.v1": "example"},
+	}
+
+	want := `Ref:        example.com/bundle:v1
+Digest:     ` + digest.FromString("manifest").String() + `
+MediaType:  application/vnd.docker.distribution.manifest.v2+json
+Size:       583
+
+Manifest:
+  {
+    "schemaVersion": 2
+  }
+
+Config:
+  Created:  1970-01-01 00:00:00 +0000 UTC
+  Platform: linux/amd64
+  Labels:
+    maintainer=olm
+    version=0.1.0
+  History:
+    - 1970-01-01 00:00:00 +0000 UTC bndlr
+
+Layers:
+  [0] ` + digest.FromString("layer").String() + ` application/vnd.docker.image.rootfs.diff.tar.gzip 264 bytes
+      crds/
+      crds/examples.example.com.crd.yaml
+
+Bundle:
+  operators.operatorframework.io.bundle.package.v1=example
+`
+
+	var out bytes.Buffer
+	if err := printImageInfo(&out, info); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out.String())
+	}
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/ecordell/bndlr/pkg/registry"
+	"github.com/ecordell/bndlr/pkg/registry/auth"
+	"github.com/ecordell/bndlr/pkg/signals"
+)
+
+type loginOptions struct {
+	configs       []string
+	username      string
+	password      string
+	passwordStdin bool
+
+	registry registryOptions
+
+	debug bool
+}
+
+var loginOpts loginOptions
+
+// loginCmd represents the login command
+var loginCmd = &cobra.Command{
+	Use:   "login <registry>",
+	Short: "Log in to a registry",
+	Long: `Login checks a username and password against a registry, fetching a token
+if the registry uses token auth, and then stores them in the first auth file that
+exists, or in the credential helper that file configures for the registry.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, err := loginOpts.registry.withRetryPolicy(signals.Context())
+		if err != nil {
+			return err
+		}
+
+		if len(args) < 1 {
+			return fmt.Errorf("should be called with one arg: registry")
+		}
+		host := args[0]
+
+		if loginOpts.debug {
+			logrus.SetLevel(logrus.DebugLevel)
+		}
+
+		if loginOpts.username == "" {
+			return fmt.Errorf("--username is required")
+		}
+		password := loginOpts.password
+		if loginOpts.passwordStdin {
+			if password != "" {
+				return fmt.Errorf("--password and --password-stdin are mutually exclusive")
+			}
+			var err error
+			password, err = readPassword(os.Stdin)
+			if err != nil {
+				return err
+			}
+		} else if password != "" {
+			logrus.Warn("using --password on the command line is insecure, use --password-stdin")
+		}
+		if password == "" {
+			return fmt.Errorf("a password is required, set --password or --password-stdin")
+		}
+
+		resolverOpts, err := loginOpts.registry.resolverOptions()
+		if err != nil {
+			return err
+		}
+		if err := registry.Login(ctx, host, loginOpts.username, password, resolverOpts...); err != nil {
+			return err
+		}
+
+		creds, err := auth.NewCredentials(authFiles(loginOpts.configs)...)
+		if err != nil {
+			return err
+		}
+		stored, err := creds.Store(host, loginOpts.username, password)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("logged in to %s, credentials stored in %s\n", host, stored)
+		return nil
+	},
+}
+
+// readPassword reads a password from the first line of r
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// authFiles returns the auth files set by --config, or the defaults if there are none
+func authFiles(configs []string) []string {
+	if len(configs) > 0 {
+		return configs
+	}
+	return auth.DefaultFiles()
+}
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+	loginCmd.Flags().StringArrayVarP(&loginOpts.configs, "config", "c", nil, "auth config path. defaults to $REGISTRY_AUTH_FILE, or podman's auth.json and ~/.docker/config.json")
+	loginCmd.Flags().StringVarP(&loginOpts.username, "username", "u", "", "username")
+	loginCmd.Flags().StringVarP(&loginOpts.password, "password", "p", "", "password")
+	loginCmd.Flags().BoolVar(&loginOpts.passwordStdin, "password-stdin", false, "read the password from stdin")
+	loginOpts.registry.addFlags(loginCmd.Flags())
+	loginCmd.Flags().BoolVarP(&loginOpts.debug, "debug", "d", false, "enable debug logging")
+}
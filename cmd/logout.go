@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ecordell/bndlr/pkg/registry/auth"
+)
+
+type logoutOptions struct {
+	configs []string
+}
+
+var logoutOpts logoutOptions
+
+// logoutCmd represents the logout command
+var logoutCmd = &cobra.Command{
+	Use:   "logout <registry>",
+	Short: "Log out of a registry",
+	Long: `Logout removes the credentials for a registry from every auth file, and from
+the credential helpers they configure.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("should be called with one arg: registry")
+		}
+		host := args[0]
+
+		creds, err := auth.NewCredentials(authFiles(logoutOpts.configs)...)
+		if err != nil {
+			return err
+		}
+		erased, err := creds.Erase(host)
+		if err != nil {
+			return err
+		}
+
+		if len(erased) == 0 {
+			fmt.Printf("not logged in to %s\n", host)
+			return nil
+		}
+		fmt.Printf("logged out of %s, removed credentials from %s\n", host, strings.Join(erased, ", "))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logoutCmd)
+	logoutCmd.Flags().StringArrayVarP(&logoutOpts.configs, "config", "c", nil, "auth config path. defaults to $REGISTRY_AUTH_FILE, or podman's auth.json and ~/.docker/config.json")
+}
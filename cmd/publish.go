@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/containerd/containerd/remotes"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+
+	"github.com/ecordell/bndlr/pkg/image"
+	"github.com/ecordell/bndlr/pkg/registry/common"
+	"github.com/ecordell/bndlr/pkg/registry/progress"
+	"github.com/ecordell/bndlr/pkg/registry/store"
+)
+
+// publishOptions are the flags that control how an image is pushed to its refs, shared by push and copy
+type publishOptions struct {
+	bestEffort bool
+	immutable  bool
+	ifMatch    string
+	progress   string
+}
+
+func (o *publishOptions) addFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.bestEffort, "best-effort", false, "push to every ref even if some fail. by default no ref is tagged unless blobs reached every repository, and pushing stops at the first failure")
+	flags.BoolVar(&o.immutable, "immutable", false, "refuse to push to tags that already exist with different content. tags that already have the same content are left alone")
+	flags.StringVar(&o.ifMatch, "if-match", "", "only push to tags that currently point to this manifest digest, to update them without overwriting someone else's push")
+	flags.StringVar(&o.progress, "progress", progressAuto, "report upload progress. Options: auto (a bar when stderr is a terminal), tty, json (events on stdout), none")
+}
+
+// pushOptions checks the flags and returns the options to push with
+func (o *publishOptions) pushOptions() ([]common.PushOption, error) {
+	var ifMatch digest.Digest
+	if o.ifMatch != "" {
+		if o.immutable {
+			return nil, fmt.Errorf("--immutable and --if-match are mutually exclusive")
+		}
+		var err error
+		if ifMatch, err = digest.Parse(o.ifMatch); err != nil {
+			return nil, fmt.Errorf("invalid --if-match: %v", err)
+		}
+	}
+	return []common.PushOption{
+		common.WithBestEffort(o.bestEffort),
+		common.WithImmutable(o.immutable),
+		common.WithIfMatch(ifMatch),
+	}, nil
+}
+
+// publish pushes an image in the store to refs, reporting progress and printing the result for each ref
+func (o *publishOptions) publish(ctx context.Context, s store.Store, resolver remotes.Resolver, image *image.Descriptor, refs []string, opts []common.PushOption) error {
+	reporter, err := newReporter(o.progress)
+	if err != nil {
+		return err
+	}
+	if reporter != nil {
+		ctx = progress.WithReporter(ctx, reporter)
+	}
+
+	results, err := common.PushToRefs(ctx, s, resolver, image, refs, opts...)
+	if o.progress == progressJSON {
+		// results are in the events, keep stdout parseable
+		for _, r := range results {
+			reporter.Report(resultEvent(r))
+		}
+	}
+	if reporter != nil {
+		if cerr := reporter.Close(); cerr != nil {
+			logrus.WithError(cerr).Warn("failed to report progress")
+		}
+	}
+	if o.progress == progressJSON {
+		return err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("failed to push %s: %v\n", r.Ref, r.Err)
+			continue
+		}
+		if r.Unchanged {
+			fmt.Printf("%s already has digest %s, nothing pushed\n", r.Ref, r.Digest.String())
+			continue
+		}
+		fmt.Printf("pushed %s with digest %s\n", r.Ref, r.Digest.String())
+	}
+	return err
+}
+
+// resultEvent reports the outcome of pushing to a ref
+func resultEvent(r common.PushResult) progress.Event {
+	e := progress.Event{
+		Type:      progress.Result,
+		Time:      time.Now(),
+		Ref:       r.Ref,
+		Digest:    r.Digest,
+		Unchanged: r.Unchanged,
+	}
+	if r.Err != nil {
+		e.Error = r.Err.Error()
+	}
+	return e
+}
+
+const (
+	progressAuto = "auto"
+	progressTTY  = "tty"
+	progressJSON = "json"
+	progressNone = "none"
+)
+
+// stderrIsTerminal decides whether --progress auto draws bars, it is replaced in tests
+var stderrIsTerminal = func() bool {
+	return progress.IsTerminal(os.Stderr)
+}
+
+// newReporter returns the progress reporter for a --progress mode, or nil if progress isn't reported
+// bars are drawn on stderr so stdout only has results, json events are written to stdout
+func newReporter(mode string) (progress.Reporter, error) {
+	switch mode {
+	case progressAuto:
+		if !stderrIsTerminal() {
+			return nil, nil
+		}
+		return progress.NewTerminalReporter(os.Stderr), nil
+	case progressTTY:
+		return progress.NewTerminalReporter(os.Stderr), nil
+	case progressJSON:
+		return progress.NewJSONReporter(os.Stdout), nil
+	case progressNone:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown progress mode %q, options: auto, tty, json, none", mode)
+}
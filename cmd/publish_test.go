@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ecordell/bndlr/pkg/registry/progress"
+)
+
+func TestNewReporter(t *testing.T) {
+	defer func(isTerminal func() bool) { stderrIsTerminal = isTerminal }(stderrIsTerminal)
+
+	tests := []struct {
+		mode     string
+		terminal bool
+		want     progress.Reporter
+		wantErr  bool
+	}{
+		{mode: progressAuto, terminal: true, want: &progress.TerminalReporter{}},
+		{mode: progressAuto, terminal: false},
+		{mode: progressTTY, terminal: false, want: &progress.TerminalReporter{}},
+		{mode: progressJSON, terminal: true, want: &progress.JSONReporter{}},
+		{mode: progressJSON, terminal: false, want: &progress.JSONReporter{}},
+		{mode: progressNone, terminal: true},
+		{mode: "bars", wantErr: true},
+	}
+	for _, tt := range tests {
+		terminal := tt.terminal
+		stderrIsTerminal = func() bool { return terminal }
+
+		r, err := newReporter(tt.mode)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: error = %v, wantErr %v", tt.mode, err, tt.wantErr)
+		}
+		if reflect.TypeOf(r) != reflect.TypeOf(tt.want) {
+			t.Errorf("%s with terminal %t: expected a %T, got %T", tt.mode, tt.terminal, tt.want, r)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/ecordell/bndlr/pkg/registry"
+	"github.com/ecordell/bndlr/pkg/registry/common"
+	"github.com/ecordell/bndlr/pkg/signals"
+)
+
+type pullOptions struct {
+	// auth
+	configs  []string
+	username string
+	password string
+
+	storeType string
+	storeDir  string
+	keepStore bool
+
+	registry registryOptions
+
+	debug bool
+}
+
+var pullOpts pullOptions
+
+// pullCmd represents the pull command
+var pullCmd = &cobra.Command{
+	Use:   "pull <ref> <dir>",
+	Short: "Pull a bundle image and unpack it into a directory",
+	Long: `Pull resolves a bundle image reference, fetches its manifest, config and layers
+into the configured storage, verifies their digests, and extracts the layers
+into the target directory.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, err := pullOpts.registry.withRetryPolicy(signals.Context())
+		if err != nil {
+			return err
+		}
+
+		if len(args) < 2 {
+			return fmt.Errorf("should be called with two args: ref dir")
+		}
+		ref := args[0]
+		dir := args[1]
+
+		if pullOpts.debug {
+			logrus.SetLevel(logrus.DebugLevel)
+		}
+
+		resolverOpts, err := pullOpts.registry.resolverOptions()
+		if err != nil {
+			return err
+		}
+		resolver, err := registry.NewResolver(pullOpts.username, pullOpts.password, pullOpts.configs, resolverOpts...)
+		if err != nil {
+			return err
+		}
+		store, err := newStore(pullOpts.storeType, pullOpts.storeDir, pullOpts.keepStore)
+		if err != nil {
+			return err
+		}
+		defer closeStore(store)
+
+		image, err := common.PullAndUnpackDirectory(ctx, ref, store, resolver, dir)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("pulled %s with digest %s into %s\n", ref, image.Manifest.Digest.String(), dir)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pullCmd)
+	pullCmd.Flags().StringArrayVarP(&pullOpts.configs, "config", "c", nil, "auth config path. defaults to $REGISTRY_AUTH_FILE, or podman's auth.json and ~/.docker/config.json")
+	pullCmd.Flags().StringVarP(&pullOpts.username, "username", "u", "", "username")
+	pullCmd.Flags().StringVarP(&pullOpts.password, "password", "p", "", "password")
+	pullOpts.registry.addFlags(pullCmd.Flags())
+	pullCmd.Flags().BoolVarP(&pullOpts.debug, "debug", "d", false, "enable debug logging")
+	pullCmd.Flags().StringVarP(&pullOpts.storeType, "storage", "s", string(TmpFileStoreType), "configure storage. Options: memory, tmp, file")
+	pullCmd.Flags().StringVar(&pullOpts.storeDir, "storagePath", "", "configure storage location. only valid for storage type file")
+	pullCmd.Flags().BoolVar(&pullOpts.keepStore, "keep-store", false, "keep the temporary directory of storage type tmp, to debug what was written")
+}
@@ -3,25 +3,18 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/containerd/containerd/reference"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
+	"github.com/ecordell/bndlr/pkg/bundle"
+	"github.com/ecordell/bndlr/pkg/image/layer"
+	"github.com/ecordell/bndlr/pkg/image/manifest"
 	"github.com/ecordell/bndlr/pkg/registry"
 	"github.com/ecordell/bndlr/pkg/registry/common"
-	"github.com/ecordell/bndlr/pkg/registry/filestore"
-	"github.com/ecordell/bndlr/pkg/registry/memory"
-	"github.com/ecordell/bndlr/pkg/registry/store"
 	"github.com/ecordell/bndlr/pkg/signals"
 )
 
-type StoreType string
-
-const (
-	MemoryStoreType StoreType = "memory"
-	TmpFileStoreType StoreType = "tmp"
-	FileStoreType StoreType = "file"
-)
-
 type pushOptions struct {
 	// auth
 	configs  []string
@@ -29,7 +22,23 @@ type pushOptions struct {
 	password string
 
 	storeType string
-	storeDir string
+	storeDir  string
+	keepStore bool
+
+	format       string
+	prefix       string
+	layers       []string
+	excludes     []string
+	includes     []string
+	reproducible bool
+	bundle       bundleOptions
+	annotations  annotationOptions
+	validate     bool
+
+	additionalTags []string
+	publish        publishOptions
+
+	registry registryOptions
 
 	debug bool
 }
@@ -38,7 +47,7 @@ var pushOpts pushOptions
 
 // pushCmd represents the push command
 var pushCmd = &cobra.Command{
-	Use:   "push",
+	Use:   "push <dir> <ref> [ref...]",
 	Short: "A brief description of your command",
 	Long: `A longer description that spans multiple lines and likely contains examples
 and usage of using your command. For example:
@@ -47,56 +56,136 @@ Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := signals.Context()
+		ctx, err := pushOpts.registry.withRetryPolicy(signals.Context())
+		if err != nil {
+			return err
+		}
 
-		if len(args) < 2  {
-			return fmt.Errorf("should be called with two args: dir host")
+		if len(args) < 2 {
+			return fmt.Errorf("should be called with at least two args: dir ref")
 		}
 		dir := args[0]
-		ref := args[1]
+		refs, err := withAdditionalTags(args[1:], pushOpts.additionalTags)
+		if err != nil {
+			return err
+		}
 
 		if pushOpts.debug {
 			logrus.SetLevel(logrus.DebugLevel)
 		}
 
-		resolver := registry.NewResolver(pushOpts.username, pushOpts.password, pushOpts.configs...)
-		var store store.Store
-		var err error
-		if pushOpts.storeType == string(MemoryStoreType) {
-			store = memory.NewMemoryStore()
-		} else if pushOpts.storeType == string(TmpFileStoreType) {
-			store, err = filestore.NewTmpFileStore()
-			if err != nil {
-				return err
-			}
-		} else if pushOpts.storeType == string(FileStoreType) {
-			if pushOpts.storeDir == "" {
-				return fmt.Errorf("must specify --storagePath when using storage type file")
-			}
-			store, err = filestore.NewFileStore(pushOpts.storeDir)
-			if err != nil {
+		publishOpts, err := pushOpts.publish.pushOptions()
+		if err != nil {
+			return err
+		}
+
+		selection := []layer.LayerOption{layer.WithExclude(pushOpts.excludes...), layer.WithInclude(pushOpts.includes...)}
+		files, err := layer.ListDirectory(dir, selection...)
+		if err != nil {
+			return err
+		}
+
+		if pushOpts.validate {
+			if err := bundle.Validate(dir, bundle.WithFiles(files)); err != nil {
 				return err
 			}
-		} else {
-			return fmt.Errorf("store type %s not supported", pushOpts.storeType)
 		}
 
-		digest, err := common.BuildAndPushDirectoryV22(ctx, ref, store, resolver, dir)
+		format, err := manifest.ParseFormat(pushOpts.format)
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("pushed with digest %s\n", digest.String())
-		return nil
+		resolverOpts, err := pushOpts.registry.resolverOptions()
+		if err != nil {
+			return err
+		}
+		resolver, err := registry.NewResolver(pushOpts.username, pushOpts.password, pushOpts.configs, resolverOpts...)
+		if err != nil {
+			return err
+		}
+		store, err := newStore(pushOpts.storeType, pushOpts.storeDir, pushOpts.keepStore)
+		if err != nil {
+			return err
+		}
+		defer closeStore(store)
+
+		metadata, prefix, err := pushOpts.bundle.metadata(dir, pushOpts.prefix, bundle.WithFiles(files))
+		if err != nil {
+			return err
+		}
+
+		layers, err := layerSpecs(pushOpts.layers)
+		if err != nil {
+			return err
+		}
+
+		manifestOpts, configOpts, err := pushOpts.annotations.options()
+		if err != nil {
+			return err
+		}
+
+		image, err := common.BuildDirectory(ctx, refs[0], store, dir,
+			common.WithFormat(format),
+			common.WithReproducible(pushOpts.reproducible),
+			common.WithLayerOptions(append(selection, layer.WithPrefix(prefix))...),
+			common.WithBundleMetadata(metadata),
+			common.WithLayers(layers...),
+			common.WithManifestOptions(manifestOpts...),
+			common.WithConfigOptions(configOpts...),
+		)
+		if err != nil {
+			return err
+		}
+
+		return pushOpts.publish.publish(ctx, store, resolver, image, refs, publishOpts)
 	},
 }
 
+// withAdditionalTags adds a ref for each tag in the repository of each ref, without duplicates
+func withAdditionalTags(refs, tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	var all []string
+	add := func(ref string) {
+		if !seen[ref] {
+			seen[ref] = true
+			all = append(all, ref)
+		}
+	}
+	for _, ref := range refs {
+		add(ref)
+	}
+	for _, ref := range refs {
+		spec, err := reference.Parse(ref)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			add(spec.Locator + ":" + tag)
+		}
+	}
+	return all, nil
+}
+
 func init() {
 	rootCmd.AddCommand(pushCmd)
-	pushCmd.Flags().StringArrayVarP(&pushOpts.configs, "config", "c", []string{"~/.docker/config.json"}, "auth config path")
+	pushCmd.Flags().StringArrayVarP(&pushOpts.configs, "config", "c", nil, "auth config path. defaults to $REGISTRY_AUTH_FILE, or podman's auth.json and ~/.docker/config.json")
 	pushCmd.Flags().StringVarP(&pushOpts.username, "username", "u", "", "username")
 	pushCmd.Flags().StringVarP(&pushOpts.password, "password", "p", "", "password")
+	pushOpts.registry.addFlags(pushCmd.Flags())
 	pushCmd.Flags().BoolVarP(&pushOpts.debug, "debug", "d", false, "enable debug logging")
-	pushCmd.Flags().StringVarP(&pushOpts.storeType, "storage", "s", string(TmpFileStoreType), "configure storage. Options: memory, tmp, file" )
-	pushCmd.Flags().StringVar(&pushOpts.storeDir, "storagePath",  "", "configure storage location. only valid for storage type file" )
+	pushCmd.Flags().StringVarP(&pushOpts.storeType, "storage", "s", string(TmpFileStoreType), "configure storage. Options: memory, tmp, file")
+	pushCmd.Flags().StringVar(&pushOpts.storeDir, "storagePath", "", "configure storage location. only valid for storage type file")
+	pushCmd.Flags().BoolVar(&pushOpts.keepStore, "keep-store", false, "keep the temporary directory of storage type tmp, to debug what was written")
+	pushCmd.Flags().StringVar(&pushOpts.format, "format", string(manifest.DockerFormat), "image media types. Options: docker, oci")
+	pushCmd.Flags().StringVar(&pushOpts.prefix, "prefix", "", "directory to store files under in the layer, i.e. manifests/")
+	pushCmd.Flags().StringArrayVar(&pushOpts.layers, "layer", nil, "put files matching path[:mediaType], a path or glob relative to the directory, into a layer of their own. repeat for more layers, remaining files go into a last layer")
+	pushCmd.Flags().StringArrayVar(&pushOpts.excludes, "exclude", nil, "leave files matching a gitignore pattern out of the image, after the patterns in the directory's .dlvrignore")
+	pushCmd.Flags().StringArrayVar(&pushOpts.includes, "include", nil, "only put files matching a gitignore pattern into the image. excluded files are still left out")
+	pushOpts.bundle.addFlags(pushCmd.Flags())
+	pushOpts.annotations.addFlags(pushCmd.Flags())
+	pushCmd.Flags().StringArrayVar(&pushOpts.additionalTags, "additional-tag", nil, "also push to this tag in the repository of each ref")
+	pushOpts.publish.addFlags(pushCmd.Flags())
+	pushCmd.Flags().BoolVar(&pushOpts.validate, "validate", true, "validate the manifests before pushing")
+	pushCmd.Flags().BoolVar(&pushOpts.reproducible, "reproducible", true, "normalize file metadata and timestamps so identical inputs produce identical digests. timestamps are taken from SOURCE_DATE_EPOCH")
 }
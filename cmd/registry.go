@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/ecordell/bndlr/pkg/registry"
+	"github.com/ecordell/bndlr/pkg/registry/retry"
+)
+
+// registryOptions are the flags that control how registries are reached, shared by commands that talk to a registry
+type registryOptions struct {
+	plainHTTP   bool
+	insecure    bool
+	caFile      string
+	certFile    string
+	keyFile     string
+	hostsConfig string
+
+	retryAttempts   int
+	retryBackoff    time.Duration
+	retryMaxBackoff time.Duration
+	retryJitter     float64
+}
+
+func (o *registryOptions) addFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.plainHTTP, "plain-http", false, "use http instead of https for all registries. localhost registries always use http")
+	flags.BoolVar(&o.insecure, "insecure-skip-tls-verify", false, "accept any certificate presented by a registry")
+	flags.StringVar(&o.caFile, "ca-file", "", "PEM bundle of certificate authorities to trust in addition to the system roots")
+	flags.StringVar(&o.certFile, "cert-file", "", "PEM client certificate to present to registries. requires --key-file")
+	flags.StringVar(&o.keyFile, "key-file", "", "PEM key for --cert-file")
+	flags.IntVar(&o.retryAttempts, "retry-attempts", retry.DefaultPolicy.Attempts, "attempts for registry requests that fail with network errors, 429 or 5xx responses. 1 disables retries")
+	flags.DurationVar(&o.retryBackoff, "retry-backoff", retry.DefaultPolicy.InitialBackoff, "wait before the first retry, doubled for each attempt after")
+	flags.DurationVar(&o.retryMaxBackoff, "retry-max-backoff", retry.DefaultPolicy.MaxBackoff, "longest wait between retries, unless a registry asks for longer with Retry-After")
+	flags.Float64Var(&o.retryJitter, "retry-jitter", retry.DefaultPolicy.Jitter, "randomize each wait between retries by up to this fraction of it, between 0 and 1. 0 disables jitter")
+	flags.StringVar(&o.hostsConfig, "hosts-config", "", "yaml file of per-host settings (plainHTTP, insecureSkipTLSVerify, caFile, certFile, keyFile) keyed by registry host")
+}
+
+// withRetryPolicy sets the retry policy from the flags on the context used for registry requests
+func (o *registryOptions) withRetryPolicy(ctx context.Context) (context.Context, error) {
+	if o.retryJitter < 0 || o.retryJitter > 1 {
+		return nil, fmt.Errorf("--retry-jitter %v should be between 0 and 1", o.retryJitter)
+	}
+	p := retry.DefaultPolicy
+	p.Attempts = o.retryAttempts
+	p.InitialBackoff = o.retryBackoff
+	p.MaxBackoff = o.retryMaxBackoff
+	p.Jitter = o.retryJitter
+	return retry.WithPolicy(ctx, p), nil
+}
+
+// resolverOptions returns the resolver options set by the flags
+func (o *registryOptions) resolverOptions() ([]registry.ResolverOption, error) {
+	opts := []registry.ResolverOption{
+		registry.WithPlainHTTP(o.plainHTTP),
+		registry.WithInsecureSkipTLSVerify(o.insecure),
+		registry.WithCAFile(o.caFile),
+		registry.WithClientCertificate(o.certFile, o.keyFile),
+	}
+	if o.hostsConfig != "" {
+		hosts, err := registry.LoadHostsConfig(o.hostsConfig)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, registry.WithHostsConfig(hosts))
+	}
+	return opts, nil
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/ecordell/bndlr/pkg/registry/retry"
+)
+
+func TestWithRetryPolicy(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    float64
+		wantErr bool
+	}{
+		{want: retry.DefaultPolicy.Jitter},
+		{args: []string{"--retry-jitter=0"}, want: 0},
+		{args: []string{"--retry-jitter=0.5"}, want: 0.5},
+		{args: []string{"--retry-jitter=1"}, want: 1},
+		{args: []string{"--retry-jitter=-0.1"}, wantErr: true},
+		{args: []string{"--retry-jitter=1.5"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		var o registryOptions
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		o.addFlags(flags)
+		if err := flags.Parse(append(tt.args, "--retry-attempts=2", "--retry-backoff=1s", "--retry-max-backoff=2s")); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, err := o.withRetryPolicy(context.Background())
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%v: error = %v, wantErr %v", tt.args, err, tt.wantErr)
+		}
+		if tt.wantErr {
+			continue
+		}
+		want := retry.Policy{Attempts: 2, InitialBackoff: time.Second, MaxBackoff: 2 * time.Second, Jitter: tt.want}
+		if got := retry.PolicyFrom(ctx); got != want {
+			t.Errorf("%v: expected policy %+v, got %+v", tt.args, want, got)
+		}
+	}
+}
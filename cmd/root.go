@@ -19,6 +19,8 @@ examples and usage of using your application. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
+  // errors are printed once by Execute
+  SilenceErrors: true,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/ecordell/bndlr/pkg/registry/server"
+	"github.com/ecordell/bndlr/pkg/signals"
+)
+
+type serveOptions struct {
+	root string
+	addr string
+
+	debug bool
+}
+
+var serveOpts serveOptions
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a local registry backed by a directory",
+	Long: `Serve runs the distribution registry (blobs, uploads, manifests, tags/list
+and catalog) with its filesystem storage driver over a local directory, so
+bundles can be pushed and pulled without any external services.
+
+The registry serves plain http, use --plain-http to push and pull from it.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := signals.Context()
+
+		if serveOpts.debug {
+			logrus.SetLevel(logrus.DebugLevel)
+		}
+		if serveOpts.root == "" {
+			return fmt.Errorf("--root is required")
+		}
+
+		handler, err := server.NewServer(serveOpts.root)
+		if err != nil {
+			return err
+		}
+		srv := &http.Server{Addr: serveOpts.addr, Handler: handler}
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- srv.ListenAndServe()
+		}()
+		logrus.WithField("addr", serveOpts.addr).WithField("root", serveOpts.root).Info("serving registry")
+
+		select {
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+		}
+
+		logrus.Info("shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&serveOpts.root, "root", "", "directory to store blobs, manifests and tags in")
+	serveCmd.Flags().StringVar(&serveOpts.addr, "addr", ":5000", "address to listen on")
+	serveCmd.Flags().BoolVarP(&serveOpts.debug, "debug", "d", false, "enable debug logging")
+}
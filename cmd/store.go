@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/ecordell/bndlr/pkg/registry/filestore"
+	"github.com/ecordell/bndlr/pkg/registry/memory"
+	"github.com/ecordell/bndlr/pkg/registry/store"
+	"github.com/ecordell/bndlr/pkg/signals"
+)
+
+type StoreType string
+
+const (
+	MemoryStoreType  StoreType = "memory"
+	TmpFileStoreType StoreType = "tmp"
+	FileStoreType    StoreType = "file"
+)
+
+// newStore returns the store.Store configured by the --storage, --storagePath and --keep-store flags
+// it must be closed with closeStore, which deletes a tmp store unless keep is set, and so does a forced exit
+func newStore(storeType, storeDir string, keep bool) (store.Store, error) {
+	switch StoreType(storeType) {
+	case MemoryStoreType:
+		return memory.NewMemoryStore(), nil
+	case TmpFileStoreType:
+		s, err := filestore.NewTmpFileStore(filestore.WithKeep(keep))
+		if err != nil {
+			return nil, err
+		}
+		signals.OnForcedExit(func() { s.Close() })
+		return s, nil
+	case FileStoreType:
+		if storeDir == "" {
+			return nil, fmt.Errorf("must specify --storagePath when using storage type file")
+		}
+		return filestore.NewFileStore(storeDir)
+	default:
+		return nil, fmt.Errorf("store type %s not supported", storeType)
+	}
+}
+
+// closeStore closes a store once a command is done with it, including when it was cancelled by a signal
+// failing to clean up is logged rather than returned, so that it doesn't hide the command's own result
+func closeStore(s store.Store) {
+	if err := s.Close(); err != nil {
+		logrus.WithError(err).Warn("can't clean up store")
+	}
+}
+
+// openStore opens an existing store directory for the store commands
+// newStore would create a missing directory, so a mistyped --storagePath would list or prune an empty store
+func openStore(storeDir string) (store.Store, error) {
+	if storeDir == "" {
+		return nil, fmt.Errorf("must specify --storagePath")
+	}
+	info, err := os.Stat(storeDir)
+	if err != nil {
+		return nil, fmt.Errorf("can't open store: %v", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("can't open store: %s is not a directory", storeDir)
+	}
+	return newStore(string(FileStoreType), storeDir, false)
+}
+
+type storeOptions struct {
+	storeDir string
+	output   string
+
+	refs []string
+	all  bool
+
+	debug bool
+}
+
+var storeOpts storeOptions
+
+// storeCmd represents the store command
+var storeCmd = &cobra.Command{
+	Use:   "store",
+	Short: "Manage a local store directory",
+	Long: `Store lists and prunes a directory used with --storage file --storagePath.
+
+Images built or pulled into the store are named by their ref, and prune
+deletes the blobs that no named image references.`,
+}
+
+// storeLsCmd represents the store ls command
+var storeLsCmd = &cobra.Command{
+	Use:          "ls",
+	Short:        "List the images named in a local store",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := signals.Context()
+		if storeOpts.output != "text" && storeOpts.output != "json" {
+			return fmt.Errorf("output %s not supported. Options: text, json", storeOpts.output)
+		}
+		s, err := openStore(storeOpts.storeDir)
+		if err != nil {
+			return err
+		}
+		defer closeStore(s)
+
+		named, err := s.List(ctx)
+		if err != nil {
+			return err
+		}
+		if storeOpts.output == "json" {
+			return json.NewEncoder(os.Stdout).Encode(named)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "REF\tDIGEST\tMEDIATYPE\tSIZE\n")
+		for _, n := range named {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", n.Ref, n.Manifest.Digest, n.Manifest.MediaType, imageSize(ctx, s, n.Ref))
+		}
+		return w.Flush()
+	},
+}
+
+// imageSize returns the total size of the blobs of a named image, or why it can't be read
+func imageSize(ctx context.Context, s store.Store, ref string) string {
+	img, err := s.Get(ctx, ref)
+	if err != nil {
+		logrus.WithError(err).WithField("ref", ref).Warn("can't read image")
+		return "unknown"
+	}
+	size := img.Manifest.Size + img.Config.Size
+	for _, l := range img.Layers {
+		size += l.Size
+	}
+	return fmt.Sprintf("%d", size)
+}
+
+// storePruneCmd represents the store prune command
+var storePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete the blobs in a local store that no named image references",
+	Long: `Prune deletes the blobs in a local store that aren't reachable from a named
+image, and any unfinished writes. It must not run while the store is in use.
+
+Use --ref to forget images before pruning, so their blobs are deleted unless
+another image references them, or --all to empty the store.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := signals.Context()
+		if storeOpts.debug {
+			logrus.SetLevel(logrus.DebugLevel)
+		}
+		s, err := openStore(storeOpts.storeDir)
+		if err != nil {
+			return err
+		}
+		defer closeStore(s)
+
+		refs := storeOpts.refs
+		if storeOpts.all {
+			named, err := s.List(ctx)
+			if err != nil {
+				return err
+			}
+			refs = nil
+			for _, n := range named {
+				refs = append(refs, n.Ref)
+			}
+		}
+		for _, ref := range refs {
+			if err := s.Untag(ctx, ref); err != nil {
+				return err
+			}
+			fmt.Printf("untagged %s\n", ref)
+		}
+
+		deleted, err := s.GC(ctx)
+		var freed int64
+		for _, desc := range deleted {
+			freed += desc.Size
+		}
+		fmt.Printf("deleted %d blobs, freed %d bytes\n", len(deleted), freed)
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(storeCmd)
+	storeCmd.AddCommand(storeLsCmd, storePruneCmd)
+	storeCmd.PersistentFlags().StringVar(&storeOpts.storeDir, "storagePath", "", "the store directory, as used with --storage file")
+	storeLsCmd.Flags().StringVarP(&storeOpts.output, "output", "o", "text", "output format. Options: text, json")
+	storePruneCmd.Flags().StringArrayVar(&storeOpts.refs, "ref", nil, "forget an image before pruning. can be repeated")
+	storePruneCmd.Flags().BoolVar(&storeOpts.all, "all", false, "forget every image before pruning, deleting all blobs")
+	storePruneCmd.Flags().BoolVarP(&storeOpts.debug, "debug", "d", false, "enable debug logging, including every deleted blob")
+}
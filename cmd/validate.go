@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ecordell/bndlr/pkg/bundle"
+	"github.com/ecordell/bndlr/pkg/image/layer"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate <dir>",
+	Short: "Check a directory of bundle manifests",
+	Long: `Validate parses every manifest in a directory and checks that it holds exactly one
+ClusterServiceVersion, that every owned CRD has a matching CustomResourceDefinition,
+that alm-examples are valid json referencing owned kinds, and that spec.version is semver.
+
+Files listed in the directory's .dlvrignore are skipped, as they are when pushing.
+All problems are reported with their file and field.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("should be called with one arg: dir")
+		}
+		dir := args[0]
+
+		files, err := layer.ListDirectory(dir)
+		if err != nil {
+			return err
+		}
+		if err := bundle.Validate(dir, bundle.WithFiles(files)); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s is valid\n", dir)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
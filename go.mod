@@ -1,18 +1,24 @@
 module github.com/ecordell/bndlr
 
-go 1.12
+go 1.14
 
 require (
 	// contains a fix for talking to quay - next semver release should be used when released
 	github.com/containerd/containerd v1.3.1-0.20191014151319-9c86b8f5ed49
 	// contains a fix for overriding manifestdescriptor - next semver release should be used when released
 	github.com/deislabs/oras v0.7.1-0.20191014162205-205efe3f40d5
+	github.com/docker/cli v0.0.0-20190506213505-d88565df0c2d
+	github.com/docker/distribution v2.7.1-0.20190205005809-0d3efadf0154+incompatible
+	github.com/docker/docker-credential-helpers v0.6.1
+	github.com/ghodss/yaml v1.0.0
 	github.com/gogo/protobuf v1.3.0 // indirect
 	github.com/golang/protobuf v1.3.1 // indirect
 	github.com/opencontainers/go-digest v1.0.0-rc1
 	github.com/opencontainers/image-spec v1.0.1
+	github.com/pkg/errors v0.8.1
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/cobra v0.0.5
+	github.com/spf13/pflag v1.0.3
 	golang.org/x/crypto v0.0.0-20190611184440-5c40567a22f8 // indirect
 	golang.org/x/net v0.0.0-20190620200207-3b0461eec859 // indirect
 	golang.org/x/sync v0.0.0-20190423024810-112230192c58 // indirect
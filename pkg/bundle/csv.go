@@ -0,0 +1,99 @@
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const ClusterServiceVersionKind = "ClusterServiceVersion"
+
+// ClusterServiceVersion holds the fields of a CSV that are needed to describe and check a bundle
+type ClusterServiceVersion struct {
+	Manifest `json:"-"`
+
+	Spec struct {
+		Version                   Version `json:"version"`
+		Maturity                  string  `json:"maturity"`
+		Replaces                  string  `json:"replaces"`
+		CustomResourceDefinitions struct {
+			Owned    []CRDDescription `json:"owned"`
+			Required []CRDDescription `json:"required"`
+		} `json:"customresourcedefinitions"`
+	} `json:"spec"`
+}
+
+// Version is the version of a CSV. yaml parses unquoted versions like `1.0` as numbers,
+// so those are kept as written to be reported by validation rather than failing to load
+type Version string
+
+func (v *Version) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = Version(s)
+		return nil
+	}
+	*v = Version(data)
+	return nil
+}
+
+// CRDDescription is an entry in the owned or required CRDs of a CSV
+type CRDDescription struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// ClusterServiceVersions returns the CSVs in a set of manifests
+func ClusterServiceVersions(manifests []Manifest) ([]ClusterServiceVersion, error) {
+	var csvs []ClusterServiceVersion
+	for _, m := range manifests {
+		if m.Kind != ClusterServiceVersionKind {
+			continue
+		}
+		csv := ClusterServiceVersion{Manifest: m}
+		if err := json.Unmarshal(m.Raw, &csv); err != nil {
+			return nil, fmt.Errorf("%s: %v", m.Location(), err)
+		}
+		csvs = append(csvs, csv)
+	}
+	return csvs, nil
+}
+
+// PackageName guesses the package of a CSV from its name, i.e. `etcdoperator` for `etcdoperator.v0.9.2`
+func (c ClusterServiceVersion) PackageName() string {
+	name := c.Metadata.Name
+	if i := strings.Index(name, ".v"); i > 0 {
+		return name[:i]
+	}
+	if i := strings.Index(name, "."); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// Head returns the CSV that is not replaced by any other CSV in the set
+func Head(csvs []ClusterServiceVersion) (*ClusterServiceVersion, error) {
+	replaced := map[string]bool{}
+	for _, csv := range csvs {
+		replaced[csv.Spec.Replaces] = true
+	}
+	var heads []ClusterServiceVersion
+	for _, csv := range csvs {
+		if !replaced[csv.Metadata.Name] {
+			heads = append(heads, csv)
+		}
+	}
+	switch len(heads) {
+	case 0:
+		return nil, fmt.Errorf("no ClusterServiceVersion found")
+	case 1:
+		return &heads[0], nil
+	default:
+		var names []string
+		for _, h := range heads {
+			names = append(names, h.Metadata.Name)
+		}
+		return nil, fmt.Errorf("more than one ClusterServiceVersion could be the latest: %s", strings.Join(names, ", "))
+	}
+}
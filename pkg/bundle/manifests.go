@@ -0,0 +1,161 @@
+package bundle
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+)
+
+// Manifest is a single kubernetes object read from a bundle directory
+type Manifest struct {
+	// Path is the file the object was read from, relative to the bundle directory
+	Path string
+	// Index is the position of the object within a multi-document file
+	Index int
+
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name        string            `json:"name"`
+		Annotations map[string]string `json:"annotations,omitempty"`
+	} `json:"metadata"`
+
+	// Raw is the object converted to json
+	Raw []byte `json:"-"`
+}
+
+// Location describes where a manifest was read from, for error messages
+func (m Manifest) Location() string {
+	if m.Index == 0 {
+		return m.Path
+	}
+	return fmt.Sprintf("%s[%d]", m.Path, m.Index)
+}
+
+// LoadOption configures which files of a directory are read as manifests
+type LoadOption func(config *loadConfig)
+
+type loadConfig struct {
+	files []string
+}
+
+func (c *loadConfig) apply(options []LoadOption) *loadConfig {
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// WithFiles only reads the given files, by slash-separated path relative to the directory, instead of walking it
+// i.e. the files that go into an image after ignore patterns are applied
+func WithFiles(files []string) LoadOption {
+	return func(config *loadConfig) {
+		if files == nil {
+			files = []string{}
+		}
+		config.files = files
+	}
+}
+
+// LoadManifests reads every yaml or json manifest in a directory, recursively
+func LoadManifests(dir string, opts ...LoadOption) ([]Manifest, error) {
+	manifests, problems, err := loadManifests(dir, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return manifests, nil
+}
+
+// loadManifests reads every manifest in a directory, collecting a problem for each document that fails to parse
+func loadManifests(dir string, opts ...LoadOption) ([]Manifest, []Problem, error) {
+	var manifests []Manifest
+	var problems []Problem
+	load := func(path string) error {
+		if !isManifestFile(path) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		for i, doc := range splitDocuments(data) {
+			m := Manifest{Path: rel, Index: i}
+			if m.Raw, err = yaml.YAMLToJSON(doc); err != nil {
+				problems = append(problems, Problem{Location: m.Location(), Message: err.Error()})
+				continue
+			}
+			if err := yaml.Unmarshal(doc, &m); err != nil {
+				problems = append(problems, Problem{Location: m.Location(), Message: err.Error()})
+				continue
+			}
+			manifests = append(manifests, m)
+		}
+		return nil
+	}
+
+	config := (&loadConfig{}).apply(opts)
+	if config.files != nil {
+		for _, file := range config.files {
+			if err := load(filepath.Join(dir, filepath.FromSlash(file))); err != nil {
+				return nil, nil, err
+			}
+		}
+		return manifests, problems, nil
+	}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return load(path)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return manifests, problems, nil
+}
+
+func isManifestFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// splitDocuments splits a multi-document yaml file, dropping empty documents
+func splitDocuments(data []byte) [][]byte {
+	var docs [][]byte
+	for _, doc := range bytes.Split(append([]byte("\n"), data...), []byte("\n---")) {
+		if len(bytes.TrimSpace(stripComments(doc))) > 0 {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+func stripComments(doc []byte) []byte {
+	var out [][]byte
+	for _, line := range bytes.Split(doc, []byte("\n")) {
+		if !bytes.HasPrefix(bytes.TrimSpace(line), []byte("#")) {
+			out = append(out, line)
+		}
+	}
+	return bytes.Join(out, []byte("\n"))
+}
@@ -0,0 +1,132 @@
+package bundle
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+const (
+	// MediaTypeRegistryV1 is the bundle format understood by OLM
+	MediaTypeRegistryV1 = "registry+v1"
+
+	// ManifestsDir is where manifests are stored in a bundle image by default
+	ManifestsDir = "manifests/"
+	// MetadataDir is where bundle metadata is stored in a bundle image
+	MetadataDir = "metadata/"
+	// AnnotationsFile is the name of the file in MetadataDir that holds the bundle annotations
+	AnnotationsFile = "annotations.yaml"
+
+	annotationPrefix = "operators.operatorframework.io.bundle."
+
+	PackageAnnotation        = "operators.operatorframework.io.bundle.package.v1"
+	ChannelsAnnotation       = "operators.operatorframework.io.bundle.channels.v1"
+	DefaultChannelAnnotation = "operators.operatorframework.io.bundle.channel.default.v1"
+	MediaTypeAnnotation      = "operators.operatorframework.io.bundle.mediatype.v1"
+	ManifestsAnnotation      = "operators.operatorframework.io.bundle.manifests.v1"
+	MetadataAnnotation       = "operators.operatorframework.io.bundle.metadata.v1"
+)
+
+// Metadata describes a bundle to OLM
+type Metadata struct {
+	Package        string
+	Channels       []string
+	DefaultChannel string
+
+	// Manifests is the directory in the image that holds the manifests
+	Manifests string
+}
+
+// InferMetadata reads the CSVs in a manifests directory to guess the package and channel of the bundle
+// the package comes from the name of the latest CSV, the channel from its maturity
+func InferMetadata(dir string, opts ...LoadOption) (*Metadata, error) {
+	manifests, err := LoadManifests(dir, opts...)
+	if err != nil {
+		return nil, err
+	}
+	csvs, err := ClusterServiceVersions(manifests)
+	if err != nil {
+		return nil, err
+	}
+	head, err := Head(csvs)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Metadata{
+		Package:   head.PackageName(),
+		Manifests: ManifestsDir,
+	}
+	if head.Spec.Maturity != "" {
+		m.Channels = []string{head.Spec.Maturity}
+		m.DefaultChannel = head.Spec.Maturity
+	}
+	return m, nil
+}
+
+// Validate checks that the metadata is complete
+func (m *Metadata) Validate() error {
+	if m.Package == "" {
+		return fmt.Errorf("bundle package must be set")
+	}
+	if len(m.Channels) == 0 {
+		return fmt.Errorf("bundle channels must be set")
+	}
+	if m.DefaultChannel == "" {
+		return fmt.Errorf("bundle default channel must be set")
+	}
+	for _, c := range m.Channels {
+		if c == m.DefaultChannel {
+			return nil
+		}
+	}
+	return fmt.Errorf("bundle default channel %s is not one of the channels %s", m.DefaultChannel, strings.Join(m.Channels, ","))
+}
+
+// Annotations returns the metadata as the annotations OLM expects on a bundle
+func (m *Metadata) Annotations() map[string]string {
+	return map[string]string{
+		PackageAnnotation:        m.Package,
+		ChannelsAnnotation:       strings.Join(m.Channels, ","),
+		DefaultChannelAnnotation: m.DefaultChannel,
+		MediaTypeAnnotation:      MediaTypeRegistryV1,
+		ManifestsAnnotation:      dirAnnotation(m.Manifests),
+		MetadataAnnotation:       MetadataDir,
+	}
+}
+
+// AnnotationsFile returns the contents of `metadata/annotations.yaml`
+func (m *Metadata) AnnotationsFile() ([]byte, error) {
+	return yaml.Marshal(struct {
+		Annotations map[string]string `json:"annotations"`
+	}{
+		Annotations: m.Annotations(),
+	})
+}
+
+// AnnotationsPath returns the path of the annotations file in the image
+func AnnotationsPath() string {
+	return path.Join(MetadataDir, AnnotationsFile)
+}
+
+// dirAnnotation formats a directory in the image as OLM expects, relative with a trailing slash
+func dirAnnotation(dir string) string {
+	dir = strings.Trim(path.Clean("/"+dir), "/")
+	if dir == "" {
+		return "/"
+	}
+	return dir + "/"
+}
+
+// AnnotationsFromLabels returns the bundle annotations mirrored into image config labels
+func AnnotationsFromLabels(labels map[string]string) map[string]string {
+	annotations := map[string]string{}
+	for k, v := range labels {
+		if strings.HasPrefix(k, annotationPrefix) {
+			annotations[k] = v
+		}
+	}
+	return annotations
+}
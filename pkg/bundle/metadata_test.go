@@ -0,0 +1,180 @@
+package bundle
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInferMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		dir     string
+		want    *Metadata
+		wantErr string
+	}{
+		{
+			name: "etcd sample bundle",
+			dir:  filepath.Join("..", "..", "manifests"),
+			want: &Metadata{Package: "etcdoperator", Channels: []string{"alpha"}, DefaultChannel: "alpha", Manifests: ManifestsDir},
+		},
+		{
+			name: "latest csv of an upgrade",
+			dir:  filepath.Join("testdata", "metadata", "upgrade"),
+			want: &Metadata{Package: "example", Channels: []string{"stable"}, DefaultChannel: "stable", Manifests: ManifestsDir},
+		},
+		{
+			name: "no maturity",
+			dir:  filepath.Join("testdata", "metadata", "no-maturity"),
+			want: &Metadata{Package: "example-operator", Manifests: ManifestsDir},
+		},
+		{
+			name:    "two latest csvs",
+			dir:     filepath.Join("testdata", "validate", "two-csvs"),
+			wantErr: "more than one ClusterServiceVersion could be the latest: example.v0.1.0, example.v0.2.0",
+		},
+		{
+			name:    "no csv",
+			dir:     filepath.Join("testdata", "validate", "no-csv"),
+			wantErr: "no ClusterServiceVersion found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InferMetadata(tt.dir)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHead(t *testing.T) {
+	csv := func(name, replaces string) ClusterServiceVersion {
+		c := ClusterServiceVersion{}
+		c.Metadata.Name = name
+		c.Spec.Replaces = replaces
+		return c
+	}
+	tests := []struct {
+		name    string
+		csvs    []ClusterServiceVersion
+		want    string
+		wantErr bool
+	}{
+		{name: "single", csvs: []ClusterServiceVersion{csv("a.v1", "")}, want: "a.v1"},
+		{name: "chain in any order", csvs: []ClusterServiceVersion{csv("a.v2", "a.v1"), csv("a.v3", "a.v2"), csv("a.v1", "")}, want: "a.v3"},
+		{name: "replaces a csv outside the set", csvs: []ClusterServiceVersion{csv("a.v2", "a.v1")}, want: "a.v2"},
+		{name: "none", wantErr: true},
+		{name: "cycle", csvs: []ClusterServiceVersion{csv("a.v1", "a.v2"), csv("a.v2", "a.v1")}, wantErr: true},
+		{name: "two heads", csvs: []ClusterServiceVersion{csv("a.v1", ""), csv("b.v1", "")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head, err := Head(tt.csvs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && head.Metadata.Name != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, head.Metadata.Name)
+			}
+		})
+	}
+}
+
+func TestMetadataValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata Metadata
+		wantErr  string
+	}{
+		{name: "valid", metadata: Metadata{Package: "example", Channels: []string{"alpha", "stable"}, DefaultChannel: "stable"}},
+		{name: "no package", metadata: Metadata{Channels: []string{"alpha"}, DefaultChannel: "alpha"}, wantErr: "package"},
+		{name: "no channels", metadata: Metadata{Package: "example", DefaultChannel: "alpha"}, wantErr: "channels"},
+		{name: "no default channel", metadata: Metadata{Package: "example", Channels: []string{"alpha"}}, wantErr: "default channel"},
+		{name: "default channel not a channel", metadata: Metadata{Package: "example", Channels: []string{"alpha", "beta"}, DefaultChannel: "stable"}, wantErr: "stable is not one of the channels alpha,beta"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.metadata.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error about %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestAnnotationsFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		manifests string
+		want      string
+	}{
+		{
+			name:      "default manifests directory",
+			manifests: ManifestsDir,
+			want: `annotations:
+  operators.operatorframework.io.bundle.channel.default.v1: stable
+  operators.operatorframework.io.bundle.channels.v1: alpha,stable
+  operators.operatorframework.io.bundle.manifests.v1: manifests/
+  operators.operatorframework.io.bundle.mediatype.v1: registry+v1
+  operators.operatorframework.io.bundle.metadata.v1: metadata/
+  operators.operatorframework.io.bundle.package.v1: example
+`,
+		},
+		{
+			name:      "manifests directory is made relative with a trailing slash",
+			manifests: "/deploy/olm",
+			want: `annotations:
+  operators.operatorframework.io.bundle.channel.default.v1: stable
+  operators.operatorframework.io.bundle.channels.v1: alpha,stable
+  operators.operatorframework.io.bundle.manifests.v1: deploy/olm/
+  operators.operatorframework.io.bundle.mediatype.v1: registry+v1
+  operators.operatorframework.io.bundle.metadata.v1: metadata/
+  operators.operatorframework.io.bundle.package.v1: example
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Metadata{Package: "example", Channels: []string{"alpha", "stable"}, DefaultChannel: "stable", Manifests: tt.manifests}
+			got, err := m.AnnotationsFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.want, got)
+			}
+			if AnnotationsPath() != "metadata/annotations.yaml" {
+				t.Errorf("expected the annotations file at metadata/annotations.yaml, got %s", AnnotationsPath())
+			}
+		})
+	}
+}
+
+func TestAnnotationsFromLabels(t *testing.T) {
+	m := &Metadata{Package: "example", Channels: []string{"alpha"}, DefaultChannel: "alpha", Manifests: ManifestsDir}
+	labels := map[string]string{"org.opencontainers.image.source": "https://example.com/bundle"}
+	for k, v := range m.Annotations() {
+		labels[k] = v
+	}
+	if got := AnnotationsFromLabels(labels); !reflect.DeepEqual(got, m.Annotations()) {
+		t.Errorf("expected only the bundle annotations, got %v", got)
+	}
+}
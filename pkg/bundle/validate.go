@@ -0,0 +1,178 @@
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	CustomResourceDefinitionKind = "CustomResourceDefinition"
+
+	// ExamplesAnnotation holds example custom resources for the owned CRDs of a CSV
+	ExamplesAnnotation = "alm-examples"
+)
+
+// semverRegexp is the regular expression suggested by https://semver.org
+var semverRegexp = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+// Problem is a single validation failure in a bundle
+type Problem struct {
+	// Location is the file (and document index) the problem was found in
+	Location string
+	// Field is the path to the offending field, if any
+	Field   string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Field == "" {
+		return fmt.Sprintf("%s: %s", p.Location, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Location, p.Field, p.Message)
+}
+
+// ValidationError is returned when a bundle has one or more problems
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := []string{fmt.Sprintf("bundle has %d problem(s):", len(e.Problems))}
+	for _, p := range e.Problems {
+		lines = append(lines, "  "+p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// crd holds the fields of a CustomResourceDefinition that a CSV can refer to
+type crd struct {
+	Spec struct {
+		Group    string `json:"group"`
+		Version  string `json:"version"`
+		Versions []struct {
+			Name string `json:"name"`
+		} `json:"versions"`
+		Names struct {
+			Kind string `json:"kind"`
+		} `json:"names"`
+	} `json:"spec"`
+}
+
+func (c crd) hasVersion(version string) bool {
+	if c.Spec.Version == version {
+		return true
+	}
+	for _, v := range c.Spec.Versions {
+		if v.Name == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks a directory of bundle manifests, returning a *ValidationError listing every problem found
+func Validate(dir string, opts ...LoadOption) error {
+	manifests, problems, err := loadManifests(dir, opts...)
+	if err != nil {
+		return err
+	}
+	problems = append(problems, validateManifests(manifests)...)
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func validateManifests(manifests []Manifest) []Problem {
+	var problems []Problem
+
+	crds := map[string]crd{}
+	var csvs []ClusterServiceVersion
+	for _, m := range manifests {
+		switch m.Kind {
+		case CustomResourceDefinitionKind:
+			var c crd
+			if err := json.Unmarshal(m.Raw, &c); err != nil {
+				problems = append(problems, Problem{Location: m.Location(), Message: err.Error()})
+				continue
+			}
+			crds[m.Metadata.Name] = c
+		case ClusterServiceVersionKind:
+			csv := ClusterServiceVersion{Manifest: m}
+			if err := json.Unmarshal(m.Raw, &csv); err != nil {
+				problems = append(problems, Problem{Location: m.Location(), Message: err.Error()})
+				continue
+			}
+			csvs = append(csvs, csv)
+		}
+	}
+
+	switch len(csvs) {
+	case 0:
+		problems = append(problems, Problem{Location: ".", Message: "no ClusterServiceVersion found"})
+	case 1:
+	default:
+		for _, csv := range csvs {
+			problems = append(problems, Problem{
+				Location: csv.Location(),
+				Field:    "kind",
+				Message:  fmt.Sprintf("found %d ClusterServiceVersions, expected exactly one", len(csvs)),
+			})
+		}
+	}
+
+	for _, csv := range csvs {
+		problems = append(problems, validateCSV(csv, crds)...)
+	}
+	return problems
+}
+
+func validateCSV(csv ClusterServiceVersion, crds map[string]crd) []Problem {
+	var problems []Problem
+	problem := func(field, format string, args ...interface{}) {
+		problems = append(problems, Problem{Location: csv.Location(), Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if !semverRegexp.MatchString(string(csv.Spec.Version)) {
+		problem("spec.version", "%q is not a valid semver version", csv.Spec.Version)
+	}
+
+	ownedKinds := map[string]bool{}
+	for i, owned := range csv.Spec.CustomResourceDefinitions.Owned {
+		field := fmt.Sprintf("spec.customresourcedefinitions.owned[%d]", i)
+		ownedKinds[owned.Kind] = true
+		c, ok := crds[owned.Name]
+		if !ok {
+			problem(field, "no CustomResourceDefinition named %s in bundle", owned.Name)
+			continue
+		}
+		if owned.Kind != c.Spec.Names.Kind {
+			problem(field+".kind", "%s does not match kind %s of CustomResourceDefinition %s", owned.Kind, c.Spec.Names.Kind, owned.Name)
+		}
+		if !c.hasVersion(owned.Version) {
+			problem(field+".version", "%s is not a version of CustomResourceDefinition %s", owned.Version, owned.Name)
+		}
+	}
+
+	examples, ok := csv.Metadata.Annotations[ExamplesAnnotation]
+	if !ok {
+		return problems
+	}
+	field := fmt.Sprintf("metadata.annotations[%s]", ExamplesAnnotation)
+	var objects []struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+	}
+	if err := json.Unmarshal([]byte(examples), &objects); err != nil {
+		problem(field, "invalid json: %v", err)
+		return problems
+	}
+	for i, o := range objects {
+		if !ownedKinds[o.Kind] {
+			problem(fmt.Sprintf("%s[%d].kind", field, i), "%s is not an owned kind", o.Kind)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,69 @@
+package bundle
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		dir string
+		// problems are the location and field of each expected problem, in the order they are reported
+		problems [][2]string
+	}{
+		{dir: "valid"},
+		{dir: "no-csv", problems: [][2]string{{".", ""}}},
+		{dir: "two-csvs", problems: [][2]string{
+			{"example.v0.1.0.clusterserviceversion.yaml", "kind"},
+			{"example.v0.2.0.clusterserviceversion.yaml", "kind"},
+		}},
+		{dir: "owned-crd-missing", problems: [][2]string{
+			{"example.clusterserviceversion.yaml", "spec.customresourcedefinitions.owned[0]"},
+		}},
+		{dir: "owned-kind-mismatch", problems: [][2]string{
+			{"example.clusterserviceversion.yaml", "spec.customresourcedefinitions.owned[0].kind"},
+		}},
+		{dir: "owned-version-mismatch", problems: [][2]string{
+			{"example.clusterserviceversion.yaml", "spec.customresourcedefinitions.owned[0].version"},
+		}},
+		{dir: "examples-invalid-json", problems: [][2]string{
+			{"example.clusterserviceversion.yaml", "metadata.annotations[alm-examples]"},
+		}},
+		{dir: "examples-unowned-kind", problems: [][2]string{
+			{"example.clusterserviceversion.yaml", "metadata.annotations[alm-examples][1].kind"},
+		}},
+		{dir: "invalid-semver", problems: [][2]string{
+			{"example.clusterserviceversion.yaml", "spec.version"},
+		}},
+		{dir: "unquoted-version", problems: [][2]string{
+			{"example.clusterserviceversion.yaml", "spec.version"},
+		}},
+		{dir: "invalid-yaml", problems: [][2]string{
+			{"broken.yaml", ""},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			err := Validate(filepath.Join("testdata", "validate", tt.dir))
+			if len(tt.problems) == 0 {
+				if err != nil {
+					t.Fatalf("expected no problems, got %v", err)
+				}
+				return
+			}
+
+			verr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("expected a *ValidationError, got %v", err)
+			}
+			var got [][2]string
+			for _, p := range verr.Problems {
+				got = append(got, [2]string{filepath.ToSlash(p.Location), p.Field})
+			}
+			if !reflect.DeepEqual(got, tt.problems) {
+				t.Errorf("expected problems %v, got %v", tt.problems, verr)
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package builder
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 
@@ -24,28 +26,78 @@ type Builder struct {
 	layerDescriptor manifest.LayerDescriptor
 }
 
+type builderConfig struct {
+	configOptions   []manifest.ConfigOption
+	manifestOptions []manifest.ManifestOption
+}
+
+// BuilderOption configures the metadata of images built by a Builder
+type BuilderOption func(config *builderConfig)
+
+func (c *builderConfig) apply(options []BuilderOption) *builderConfig {
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// WithConfigOptions applies options, i.e. manifest.WithCreated, to the generated config
+func WithConfigOptions(opts ...manifest.ConfigOption) BuilderOption {
+	return func(config *builderConfig) {
+		config.configOptions = append(config.configOptions, opts...)
+	}
+}
+
+// WithManifestOptions applies options, i.e. manifest.WithAnnotations, to the generated manifest
+func WithManifestOptions(opts ...manifest.ManifestOption) BuilderOption {
+	return func(config *builderConfig) {
+		config.manifestOptions = append(config.manifestOptions, opts...)
+	}
+}
 
 // NewMinimalV22ImageBuilder creates a v2-2 image with minimal metadata
-func NewMinimalV22Builder() (*Builder, error) {
+func NewMinimalV22Builder(opts ...BuilderOption) (*Builder, error) {
+	config := (&builderConfig{}).apply(opts)
 	return &Builder{
-		manifestDescriptor: manifest.ManifestDescriptorFunc(manifest.NewV22Manifest),
-		configDescriptor:   manifest.ConfigDescriptorFunc(manifest.NewMinimalV22Config),
+		manifestDescriptor: manifest.NewV22ManifestDescriptor(config.manifestOptions...),
+		configDescriptor:   manifest.NewMinimalV22ConfigDescriptor(config.configOptions...),
 		layerDescriptor:    manifest.LayerDescriptorFunc(manifest.NewLayerDescriptor),
 	}, nil
 }
 
+// NewMinimalOCIBuilder creates an oci image with minimal metadata
+func NewMinimalOCIBuilder(opts ...BuilderOption) (*Builder, error) {
+	config := (&builderConfig{}).apply(opts)
+	return &Builder{
+		manifestDescriptor: manifest.NewOCIManifestDescriptor(config.manifestOptions...),
+		configDescriptor:   manifest.NewMinimalOCIConfigDescriptor(config.configOptions...),
+		layerDescriptor:    manifest.LayerDescriptorFunc(manifest.NewLayerDescriptor),
+	}, nil
+}
+
+// NewMinimalBuilder creates an image with minimal metadata in the given format
+func NewMinimalBuilder(format manifest.Format, opts ...BuilderOption) (*Builder, error) {
+	switch format {
+	case manifest.DockerFormat:
+		return NewMinimalV22Builder(opts...)
+	case manifest.OCIFormat:
+		return NewMinimalOCIBuilder(opts...)
+	default:
+		return nil, fmt.Errorf("format %s not supported", format)
+	}
+}
+
 // BuildImage builds a manifest and config from the configured layers and writes them into a store
+// layers are streamed into the store as they are built
 func (c Builder) BuildImage(ctx context.Context, ref string, store store.Store, layers layer.Layers) (*image.Descriptor, error) {
 	var layerDescs = make([]ocispec.Descriptor, 0)
 	for _, l := range layers {
-		if err := store.Write(ctx, ref, ocispec.Descriptor{}, l.Blob); err != nil {
-			return nil, err
-		}
-		dbytes, d, err := c.layerDescriptor.MakeDescriptor(l)
+		blob, err := writeLayer(ctx, ref, store, l)
 		if err != nil {
 			return nil, err
 		}
-		if err := store.Write(ctx, ref, d, dbytes); err != nil {
+		d, err := c.layerDescriptor.MakeDescriptor(*l, blob)
+		if err != nil {
 			return nil, err
 		}
 		layerDescs = append(layerDescs, d)
@@ -55,7 +107,7 @@ func (c Builder) BuildImage(ctx context.Context, ref string, store store.Store,
 	if err != nil {
 		return nil, err
 	}
-	if err := store.Write(ctx, ref, config, configBytes); err != nil {
+	if _, err := store.Write(ctx, ref, config, bytes.NewReader(configBytes)); err != nil {
 		return nil, err
 	}
 
@@ -63,7 +115,7 @@ func (c Builder) BuildImage(ctx context.Context, ref string, store store.Store,
 	if err != nil {
 		return nil, err
 	}
-	if err := store.Write(ctx, ref, manifestDescriptor, manifestBytes); err != nil {
+	if _, err := store.Write(ctx, ref, manifestDescriptor, bytes.NewReader(manifestBytes)); err != nil {
 		return nil, err
 	}
 	return &image.Descriptor{
@@ -72,3 +124,11 @@ func (c Builder) BuildImage(ctx context.Context, ref string, store store.Store,
 		Layers:   layerDescs,
 	}, nil
 }
+
+// writeLayer streams a layer into the store, returning the descriptor of the stored blob
+func writeLayer(ctx context.Context, ref string, store store.Store, l *layer.Layer) (ocispec.Descriptor, error) {
+	blob := l.Open()
+	defer blob.Close()
+
+	return store.Write(ctx, ref, ocispec.Descriptor{MediaType: l.MediaType}, blob)
+}
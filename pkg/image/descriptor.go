@@ -1,6 +1,8 @@
 package image
 
 import (
+	"encoding/json"
+
 	"github.com/opencontainers/image-spec/specs-go/v1"
 )
 
@@ -9,3 +11,17 @@ type Descriptor struct {
 	Config   v1.Descriptor
 	Layers   []v1.Descriptor
 }
+
+// DescriptorFromManifest builds a Descriptor from a manifest descriptor and the raw manifest it describes
+// v2-2 and oci manifests share the same layout, so both can be read here
+func DescriptorFromManifest(manifest v1.Descriptor, manifestBytes []byte) (*Descriptor, error) {
+	var m v1.Manifest
+	if err := json.Unmarshal(manifestBytes, &m); err != nil {
+		return nil, err
+	}
+	return &Descriptor{
+		Manifest: manifest,
+		Config:   m.Config,
+		Layers:   m.Layers,
+	}, nil
+}
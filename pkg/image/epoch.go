@@ -0,0 +1,25 @@
+package image
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SourceDateEpochEnv is the environment variable used to set the timestamp of reproducible builds
+// see https://reproducible-builds.org/specs/source-date-epoch/
+const SourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// SourceDateEpoch returns the time set in SOURCE_DATE_EPOCH, or the unix epoch if it is unset
+func SourceDateEpoch() (time.Time, error) {
+	value := os.Getenv(SourceDateEpochEnv)
+	if value == "" {
+		return time.Unix(0, 0).UTC(), nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: %v", SourceDateEpochEnv, value, err)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
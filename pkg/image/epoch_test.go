@@ -0,0 +1,38 @@
+package image
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSourceDateEpoch(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "", want: time.Unix(0, 0).UTC()},
+		{value: "1570752000", want: time.Date(2019, 10, 11, 0, 0, 0, 0, time.UTC)},
+		{value: "yesterday", wantErr: true},
+	}
+	defer os.Setenv(SourceDateEpochEnv, os.Getenv(SourceDateEpochEnv))
+	for _, tt := range tests {
+		if err := os.Setenv(SourceDateEpochEnv, tt.value); err != nil {
+			t.Fatal(err)
+		}
+		got, err := SourceDateEpoch()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %s", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", tt.value, err)
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%q: expected %s, got %s", tt.value, tt.want, got)
+		}
+	}
+}
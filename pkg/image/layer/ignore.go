@@ -0,0 +1,171 @@
+package layer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFile lists files to leave out of a layer in gitignore syntax, it is read from the root of the layer's directory
+// the ignore file itself is never written into the layer
+const IgnoreFile = ".dlvrignore"
+
+// readIgnoreFile returns the patterns in the ignore file of a directory, if it has one
+func readIgnoreFile(directory string) ([]string, error) {
+	f, err := os.Open(filepath.Join(directory, IgnoreFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readPatterns(f)
+}
+
+func readPatterns(r io.Reader) ([]string, error) {
+	var patterns []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	return patterns, scanner.Err()
+}
+
+// rule is a single gitignore pattern
+type rule struct {
+	regexp  *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// rules are gitignore patterns, the last pattern that matches a path decides if it is selected
+type rules []rule
+
+// compileRules compiles patterns in gitignore syntax, skipping blank lines and comments
+func compileRules(patterns []string) (rules, error) {
+	var compiled rules
+	for _, p := range patterns {
+		r, ok, err := compileRule(p)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			compiled = append(compiled, r)
+		}
+	}
+	return compiled, nil
+}
+
+func compileRule(pattern string) (rule, bool, error) {
+	line := pattern
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " \t\r")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false, nil
+	}
+
+	var r rule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// patterns with a slash are relative to the root, others match at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return rule{}, false, fmt.Errorf("invalid pattern %q", pattern)
+	}
+
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return rule{}, false, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+	r.regexp = re
+	return r, true, nil
+}
+
+// globToRegexp translates a gitignore glob into a regular expression
+// `*` and `?` don't match slashes, `**` matches any number of directories
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**") && i+2 == len(glob) && i > 0 && glob[i-1] == '/':
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// match returns whether the last rule that matches p selects it, and whether any rule matched
+func (r rules) match(p string, isDir bool) (selected, matched bool) {
+	for _, rule := range r {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.regexp.MatchString(p) {
+			selected, matched = !rule.negate, true
+		}
+	}
+	return
+}
+
+// excludes reports whether a path is excluded by the rules. parents aren't checked, excluded directories are skipped whole
+func (r rules) excludes(p string, isDir bool) bool {
+	selected, _ := r.match(p, isDir)
+	return selected
+}
+
+// includes reports whether a file is selected by the rules, directly or through one of its parent directories
+func (r rules) includes(file string) bool {
+	included := false
+	parts := strings.Split(file, "/")
+	for i := range parts {
+		if selected, matched := r.match(strings.Join(parts[:i+1], "/"), i < len(parts)-1); matched {
+			included = selected
+		}
+	}
+	return included
+}
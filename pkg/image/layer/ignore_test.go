@@ -0,0 +1,180 @@
+package layer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRulesExcludes(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{name: "glob at any depth", patterns: []string{"*.swp"}, path: "a.swp", want: true},
+		{name: "glob in a directory", patterns: []string{"*.swp"}, path: "dir/a.swp", want: true},
+		{name: "glob doesn't match", patterns: []string{"*.swp"}, path: "a.yaml", want: false},
+		{name: "star doesn't cross directories", patterns: []string{"docs/*.md"}, path: "docs/sub/a.md", want: false},
+		{name: "question mark doesn't match a slash", patterns: []string{"a?c"}, path: "a/c", want: false},
+		{name: "question mark", patterns: []string{"a?c"}, path: "abc", want: true},
+		{name: "character class", patterns: []string{"tmp[0-9]"}, path: "tmp1", want: true},
+		{name: "negated character class", patterns: []string{"[!a]x"}, path: "ax", want: false},
+
+		{name: "negation", patterns: []string{"*.yaml", "!keep.yaml"}, path: "keep.yaml", want: false},
+		{name: "negation at any depth", patterns: []string{"*.yaml", "!keep.yaml"}, path: "dir/keep.yaml", want: false},
+		{name: "negation leaves other matches", patterns: []string{"*.yaml", "!keep.yaml"}, path: "other.yaml", want: true},
+		{name: "last match wins", patterns: []string{"!keep.yaml", "*.yaml"}, path: "keep.yaml", want: true},
+
+		{name: "leading slash anchors", patterns: []string{"/root.yaml"}, path: "root.yaml", want: true},
+		{name: "leading slash doesn't match deeper", patterns: []string{"/root.yaml"}, path: "dir/root.yaml", want: false},
+		{name: "middle slash anchors", patterns: []string{"docs/*.md"}, path: "docs/a.md", want: true},
+		{name: "middle slash doesn't match deeper", patterns: []string{"docs/*.md"}, path: "x/docs/a.md", want: false},
+
+		{name: "leading double star at the root", patterns: []string{"**/tests"}, path: "tests", isDir: true, want: true},
+		{name: "leading double star at any depth", patterns: []string{"**/tests"}, path: "a/b/tests", isDir: true, want: true},
+		{name: "middle double star with no directories", patterns: []string{"a/**/b"}, path: "a/b", want: true},
+		{name: "middle double star with directories", patterns: []string{"a/**/b"}, path: "a/x/y/b", want: true},
+		{name: "middle double star is anchored", patterns: []string{"a/**/b"}, path: "x/a/b", want: false},
+		{name: "trailing double star matches contents", patterns: []string{"build/**"}, path: "build/x/y", want: true},
+		{name: "trailing double star doesn't match the directory", patterns: []string{"build/**"}, path: "build", isDir: true, want: false},
+
+		{name: "directory only pattern matches directories", patterns: []string{"foo/"}, path: "foo", isDir: true, want: true},
+		{name: "directory only pattern at any depth", patterns: []string{"foo/"}, path: "a/foo", isDir: true, want: true},
+		{name: "directory only pattern skips files", patterns: []string{"foo/"}, path: "foo", want: false},
+
+		{name: "comment", patterns: []string{"#notes"}, path: "#notes", want: false},
+		{name: "escaped hash", patterns: []string{`\#notes`}, path: "#notes", want: true},
+		{name: "escaped bang", patterns: []string{`\!important`}, path: "!important", want: true},
+		{name: "trailing spaces are trimmed", patterns: []string{"foo.txt   "}, path: "foo.txt", want: true},
+		{name: "blank lines are skipped", patterns: []string{"", "  "}, path: "foo.txt", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := compileRules(tt.patterns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rules.excludes(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("%q excludes %s: expected %t, got %t", tt.patterns, tt.path, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRulesIncludes(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		file     string
+		want     bool
+	}{
+		{name: "matching file", patterns: []string{"*.yaml"}, file: "a/b.yaml", want: true},
+		{name: "other file", patterns: []string{"*.yaml"}, file: "a/b.txt", want: false},
+		{name: "file in matching directory", patterns: []string{"manifests/"}, file: "manifests/x.txt", want: true},
+		{name: "directory pattern doesn't match the file", patterns: []string{"manifests/"}, file: "manifests", want: false},
+		{name: "negated file in matching directory", patterns: []string{"manifests/", "!manifests/secret.yaml"}, file: "manifests/secret.yaml", want: false},
+		{name: "negated directory", patterns: []string{"*.yaml", "!vendor/"}, file: "vendor/a.yaml", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := compileRules(tt.patterns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rules.includes(tt.file); got != tt.want {
+				t.Errorf("%q includes %s: expected %t, got %t", tt.patterns, tt.file, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestInvalidPattern(t *testing.T) {
+	for _, p := range []string{"/", "!/"} {
+		if _, err := compileRules([]string{p}); err == nil {
+			t.Errorf("expected %q to be invalid", p)
+		}
+	}
+}
+
+func TestListDirectorySelection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bndlr-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		IgnoreFile:         "# scratch files\n*.tmp\n!keep.tmp\nsecret/\n",
+		"a.yaml":           "a",
+		"b.txt":            "b",
+		"c.tmp":            "c",
+		"keep.tmp":         "keep",
+		"secret/s.yaml":    "s",
+		"sub/d.yaml":       "d",
+		"sub/e.md":         "e",
+		"sub/deep/f.yaml":  "f",
+		"sub/deep/g.tmp":   "g",
+		"other/keep.tmp":   "other",
+		"other/secret.txt": "not a directory",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts []LayerOption
+		want []string
+	}{
+		{
+			name: "ignore file",
+			want: []string{"a.yaml", "b.txt", "keep.tmp", "other/keep.tmp", "other/secret.txt", "sub/d.yaml", "sub/deep/f.yaml", "sub/e.md"},
+		},
+		{
+			name: "exclude after ignore file",
+			opts: []LayerOption{WithExclude("sub/deep/", "/keep.tmp")},
+			want: []string{"a.yaml", "b.txt", "other/keep.tmp", "other/secret.txt", "sub/d.yaml", "sub/e.md"},
+		},
+		{
+			name: "exclude negates ignore file",
+			opts: []LayerOption{WithExclude("!c.tmp")},
+			want: []string{"a.yaml", "b.txt", "c.tmp", "keep.tmp", "other/keep.tmp", "other/secret.txt", "sub/d.yaml", "sub/deep/f.yaml", "sub/e.md"},
+		},
+		{
+			name: "include",
+			opts: []LayerOption{WithInclude("*.yaml", "*.tmp")},
+			want: []string{"a.yaml", "keep.tmp", "other/keep.tmp", "sub/d.yaml", "sub/deep/f.yaml"},
+		},
+		{
+			name: "include with ignore file and exclude",
+			opts: []LayerOption{WithInclude("*.yaml", "*.tmp", "sub/"), WithExclude("sub/deep/")},
+			want: []string{"a.yaml", "keep.tmp", "other/keep.tmp", "sub/d.yaml", "sub/e.md"},
+		},
+		{
+			name: "include can't select excluded files",
+			opts: []LayerOption{WithInclude("secret/", "c.tmp")},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ListDirectory(dir, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
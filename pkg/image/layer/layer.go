@@ -2,27 +2,53 @@ package layer
 
 import (
 	"archive/tar"
-	"bytes"
 	"compress/gzip"
 	"crypto/sha256"
+	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
 )
 
-// Layer represents a single layer: a compressed blob and a digest of the uncompressed blob
+// Layer represents a single layer: a directory that is streamed as a compressed blob, and a digest of the uncompressed blob
 type Layer struct {
-	Blob      []byte
+	// Digest is the digest of the uncompressed blob. It is set once the layer has been written
 	Digest    digest.Digest
 	MediaType string
 	Name      string
+
+	// Prefix is prepended to the path of every file written into the layer
+	Prefix string
+
+	// Reproducible normalizes file metadata so that the same files always produce the same blob
+	Reproducible bool
+	// Epoch is the latest modification time recorded in a reproducible layer
+	Epoch time.Time
+
+	// Files are additional files written into the layer after the directory, keyed by path in the layer
+	Files map[string][]byte
+
+	// Exclude are gitignore patterns of files in the directory to leave out of the layer
+	// the patterns in the directory's IgnoreFile come first
+	Exclude []string
+	// Include are gitignore patterns of the files in the directory to write into the layer, all files are written if it is empty
+	Include []string
+
+	// Filter selects the files of the directory that are written into the layer, by slash-separated path relative to the directory
+	// when it is set, directories are only written as parents of selected files
+	Filter func(path string) bool
+
+	// directory is the source of the layer's files
+	directory string
+	root      os.FileInfo
 }
 
-type Layers []Layer
+type Layers []*Layer
 
 func (l Layers) Digests() (digests []digest.Digest) {
 	for _, d := range l {
@@ -53,62 +79,247 @@ func WithName(name string) LayerOption {
 	}
 }
 
-// LayerFromDirectory builds a single tgz image layer from a directory of files
-// returns the gzip data in a byte buffer and the digest of the uncompressed files
+// WithPrefix stores files under the given directory in the layer, i.e. `manifests/`
+func WithPrefix(prefix string) LayerOption {
+	return func(layer *Layer) {
+		layer.Prefix = prefix
+	}
+}
+
+// WithReproducible normalizes ownership and permissions and clamps modification times to epoch,
+// so that the layer digest only depends on file names and contents
+func WithReproducible(epoch time.Time) LayerOption {
+	return func(layer *Layer) {
+		layer.Reproducible = true
+		layer.Epoch = epoch
+	}
+}
+
+// WithFile adds a file that is not in the directory to the layer, i.e. generated metadata
+// the path is relative to the root of the layer and is not affected by the prefix
+func WithFile(path string, content []byte) LayerOption {
+	return func(layer *Layer) {
+		if layer.Files == nil {
+			layer.Files = map[string][]byte{}
+		}
+		layer.Files[path] = content
+	}
+}
+
+// WithExclude leaves files matching gitignore patterns out of the layer, after the patterns of the directory's IgnoreFile
+func WithExclude(patterns ...string) LayerOption {
+	return func(layer *Layer) {
+		layer.Exclude = append(layer.Exclude, patterns...)
+	}
+}
+
+// WithInclude only writes files matching gitignore patterns, or in directories matching them, into the layer
+// files are still excluded by the ignore file and WithExclude
+func WithInclude(patterns ...string) LayerOption {
+	return func(layer *Layer) {
+		layer.Include = append(layer.Include, patterns...)
+	}
+}
+
+// WithFilter only writes the files of the directory whose slash-separated path relative to it is selected by filter
+func WithFilter(filter func(path string) bool) LayerOption {
+	return func(layer *Layer) {
+		layer.Filter = filter
+	}
+}
+
+// LayerFromDirectory returns a single tgz image layer that is built from a directory of files when written
+// paths in the layer are relative to the directory, so nested directories are preserved
+// files listed in the directory's IgnoreFile are left out
 func LayerFromDirectory(directory string, opts ...LayerOption) (*Layer, error) {
-	if _, err := os.Stat(directory); err != nil {
+	root, err := os.Stat(directory)
+	if err != nil {
+		return nil, err
+	}
+	ignored, err := readIgnoreFile(directory)
+	if err != nil {
+		return nil, err
+	}
+	l := (&Layer{}).apply(opts)
+	l.Exclude = append(append([]string{"/" + IgnoreFile}, ignored...), l.Exclude...)
+	l.directory = directory
+	l.root = root
+	return l, nil
+}
+
+// ListDirectory returns the slash-separated paths, relative to the directory, of the files a layer built from it with opts would have
+func ListDirectory(directory string, opts ...LayerOption) ([]string, error) {
+	l, err := LayerFromDirectory(directory, opts...)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	err = l.walk(func(rel string, info os.FileInfo) error {
+		if !info.IsDir() {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// List returns the paths in the layer of the files it has, including generated files, in the order they are written
+func (l *Layer) List() ([]string, error) {
+	if l.root == nil {
+		return nil, fmt.Errorf("layer has no source directory")
+	}
+	prefix := cleanPath(l.Prefix)
+	var files []string
+	if err := l.walk(func(rel string, info os.FileInfo) error {
+		if !info.IsDir() {
+			files = append(files, layerPath(prefix, rel))
+		}
+		return nil
+	}); err != nil {
 		return nil, err
 	}
+	var generated []string
+	for p := range l.Files {
+		generated = append(generated, cleanPath(p))
+	}
+	sort.Strings(generated)
+	return append(files, generated...), nil
+}
+
+// selective reports whether only some files of the directory are written, in which case directories are only written as parents of files
+func (l *Layer) selective() bool {
+	return l.Filter != nil || len(l.Include) > 0
+}
+
+// walk calls fn with the directories and files of the directory that go into the layer, by slash-separated path relative to it
+// Walk visits files in lexical order, so entries are always written in the same order
+// directories are skipped if the layer is selective
+func (l *Layer) walk(fn func(rel string, info os.FileInfo) error) error {
+	exclude, err := compileRules(l.Exclude)
+	if err != nil {
+		return err
+	}
+	include, err := compileRules(l.Include)
+	if err != nil {
+		return err
+	}
+
+	directory := l.directory
+	return filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(directory, path)
+		if err != nil {
+			return err
+		}
+
+		// the root directory itself is represented by the prefix, if any
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if exclude.excludes(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			if l.selective() {
+				return nil
+			}
+			return fn(rel, info)
+		}
+		if len(l.Include) > 0 && !include.includes(rel) {
+			return nil
+		}
+		if l.Filter != nil && !l.Filter(rel) {
+			return nil
+		}
+		return fn(rel, info)
+	})
+}
+
+// Open starts building the layer in the background and returns a reader of the compressed blob
+// Digest is set once the reader has been read to EOF. Closing the reader early stops the build
+func (l *Layer) Open() io.ReadCloser {
+	reader, writer := io.Pipe()
+	go func() {
+		_, err := l.WriteTo(writer)
+		writer.CloseWithError(err)
+	}()
+	return reader
+}
+
+// WriteTo builds the layer, writing the compressed blob to w and setting Digest
+// nothing is buffered, so memory use does not grow with the size of the directory
+func (l *Layer) WriteTo(w io.Writer) (int64, error) {
+	if l.root == nil {
+		return 0, fmt.Errorf("layer has no source directory")
+	}
 
 	// set up our layer pipeline
 	//
-	//                  -> gz -> byte buffer
+	//                  -> gz -> w
 	//                /
 	// files -> tar -
 	//                \
 	//                  -> sha256 -> digest
 	//
 
-	// the byte buffer contains compressed layer data,
+	// w receives compressed layer data,
 	// and the hash is the digest of the uncompressed layer
 	// data, which docker requires (oci does not)
 
 	// output writers
 	hash := sha256.New()
-	var buf bytes.Buffer
+	counter := &countingWriter{w: w}
 
-	// from gzip to buffer
-	gzipWriter := gzip.NewWriter(&buf)
+	// from gzip to w
+	gzipWriter := gzip.NewWriter(counter)
+	if l.Reproducible {
+		// no name, comment, or timestamp in the gzip header
+		gzipWriter.Header = gzip.Header{OS: 255}
+	}
 
 	// from files to hash/gz
 	hashAndGzWriter := io.MultiWriter(hash, gzipWriter)
-	writer := tar.NewWriter(hashAndGzWriter)
+	writer := newLayerWriter(tar.NewWriter(hashAndGzWriter), l, l.root)
 
-	if err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+	prefix := cleanPath(l.Prefix)
+	if err := writer.writeDirs(prefix); err != nil {
+		return counter.n, err
+	}
 
-		if !info.Mode().IsRegular() {
-			return nil
-		}
+	if err := l.walk(func(rel string, info os.FileInfo) error {
+		name := layerPath(prefix, rel)
 
-		header, err := tar.FileInfoHeader(info, info.Name())
-		if err != nil {
+		// if it's a directory, just write the header and continue
+		if info.IsDir() {
+			return writer.writeDirs(name)
+		}
+		// selective layers only get the directories of the files they select
+		if err := writer.writeParents(name); err != nil {
 			return err
 		}
 
-		err = writer.WriteHeader(header)
+		header, err := tar.FileInfoHeader(info, "")
 		if err != nil {
 			return err
 		}
-
-		// if it's a directory, just write the header and continue
-		if info.IsDir() {
-			return nil
+		header.Name = name
+		if err := writer.writeHeader(header); err != nil {
+			return err
 		}
 
-		file, err := os.Open(path)
+		file, err := os.Open(filepath.Join(l.directory, filepath.FromSlash(rel)))
 		if err != nil {
 			return err
 		}
@@ -119,31 +330,36 @@ func LayerFromDirectory(directory string, opts ...LayerOption) (*Layer, error) {
 		}()
 
 		_, err = io.Copy(writer, file)
-		if err != nil {
-			return err
-		}
-
 		return err
 	}); err != nil {
-		return nil, err
+		return counter.n, err
+	}
+
+	if err := writer.writeFiles(l.Files); err != nil {
+		return counter.n, err
 	}
 
 	// close writer here to get the correct hash - defer will not work
 	if err := writer.Close(); err != nil {
-		return nil, err
+		return counter.n, err
 	}
 
 	if err := gzipWriter.Close(); err != nil {
-		return nil, err
+		return counter.n, err
 	}
 
-	b, err := ioutil.ReadAll(&buf)
-	if err != nil {
-		return nil, err
-	}
-	l := &Layer{
-		Blob: b,
-		Digest: digest.NewDigestFromBytes(digest.SHA256, hash.Sum(nil)),
-	}
-	return l.apply(opts), nil
+	l.Digest = digest.NewDigestFromBytes(digest.SHA256, hash.Sum(nil))
+	return counter.n, nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
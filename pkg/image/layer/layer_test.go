@@ -0,0 +1,101 @@
+package layer
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2019, 10, 11, 0, 0, 0, 0, time.UTC)
+
+func TestReproducibleIgnoresMetadata(t *testing.T) {
+	files := map[string]string{
+		"a.yaml":          "a",
+		"sub/b.yaml":      "b",
+		"sub/deep/run.sh": "#!/bin/sh",
+	}
+	first := writeTree(t, files)
+	second := writeTree(t, files)
+
+	// the same files, checked out at different times by different users with a different umask
+	for i, dir := range []string{first, second} {
+		mtime := epoch.Add(time.Duration(i+1) * time.Hour)
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			mode := os.FileMode(0644)
+			if info.IsDir() {
+				mode = 0755
+			}
+			if i == 1 {
+				mode &^= 0044
+			}
+			if filepath.Base(path) == "run.sh" {
+				mode |= 0100
+			}
+			if err := os.Chmod(path, mode); err != nil {
+				return err
+			}
+			if os.Getuid() == 0 {
+				if err := os.Lchown(path, 1000*i, 1000*i); err != nil {
+					return err
+				}
+			}
+			return os.Chtimes(path, mtime, mtime)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l1, blob1 := build(t, first, WithReproducible(epoch))
+	l2, blob2 := build(t, second, WithReproducible(epoch))
+	if l1.Digest != l2.Digest {
+		t.Errorf("expected the same diffID, got %s and %s", l1.Digest, l2.Digest)
+	}
+	if string(blob1) != string(blob2) {
+		t.Error("expected the same compressed blob")
+	}
+
+	for _, h := range headers(t, blob1) {
+		if h.Uid != 0 || h.Gid != 0 || h.Uname != "" || h.Gname != "" {
+			t.Errorf("%s: expected root ownership, got %d:%d (%s:%s)", h.Name, h.Uid, h.Gid, h.Uname, h.Gname)
+		}
+		want := int64(0644)
+		if filepath.Base(h.Name) == "run.sh" || h.Typeflag == tar.TypeDir {
+			want = 0755
+		}
+		if h.Mode != want {
+			t.Errorf("%s: expected mode %o, got %o", h.Name, want, h.Mode)
+		}
+	}
+}
+
+func TestReproducibleClampsTimestamps(t *testing.T) {
+	dir := writeTree(t, map[string]string{"old.yaml": "old", "new.yaml": "new"})
+	old := epoch.Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "old.yaml"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	newer := epoch.Add(time.Hour + 500*time.Millisecond)
+	if err := os.Chtimes(filepath.Join(dir, "new.yaml"), newer, newer); err != nil {
+		t.Fatal(err)
+	}
+
+	_, blob := build(t, dir, WithReproducible(epoch))
+	want := map[string]time.Time{"old.yaml": old, "new.yaml": epoch}
+	for _, h := range headers(t, blob) {
+		if w, ok := want[h.Name]; ok && !h.ModTime.Equal(w) {
+			t.Errorf("%s: expected modification time %s, got %s", h.Name, w, h.ModTime)
+		}
+	}
+
+	l1, _ := build(t, dir, WithReproducible(epoch))
+	l2, _ := build(t, dir, WithReproducible(epoch.Add(-2*time.Hour)))
+	if l1.Digest == l2.Digest {
+		t.Error("expected a different epoch to change the diffID")
+	}
+}
@@ -0,0 +1,29 @@
+package layer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+)
+
+// ListFiles returns the path of every entry in a tgz image layer, in the order they are stored
+func ListFiles(r io.Reader) ([]string, error) {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gzipReader.Close()
+
+	var files []string
+	reader := tar.NewReader(gzipReader)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, header.Name)
+	}
+}
@@ -0,0 +1,117 @@
+package layer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// UnpackLayer extracts a single tgz image layer into a directory
+// returns the digest of the uncompressed layer data, which can be compared to the diffID in the image config
+func UnpackLayer(r io.Reader, directory string) (digest.Digest, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return "", err
+	}
+
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := gzipReader.Close(); err != nil {
+			logrus.Warnf("error closing gzip reader: %s", err.Error())
+		}
+	}()
+
+	// hash everything the tar reader consumes to get the digest of the uncompressed layer
+	hash := sha256.New()
+	reader := tar.NewReader(io.TeeReader(gzipReader, hash))
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		path, err := unpackPath(directory, header.Name)
+		if err != nil {
+			return "", err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return "", err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := unpackFile(path, reader, os.FileMode(header.Mode).Perm()); err != nil {
+				return "", err
+			}
+		default:
+			logrus.Debugf("skipping unsupported entry %s of type %c", header.Name, header.Typeflag)
+		}
+	}
+
+	// drain any trailing padding so the digest covers the whole uncompressed stream
+	if _, err := io.Copy(hash, gzipReader); err != nil {
+		return "", err
+	}
+
+	return digest.NewDigestFromBytes(digest.SHA256, hash.Sum(nil)), nil
+}
+
+// DiffID returns the digest of the uncompressed data of a tgz image layer, without extracting it
+func DiffID(r io.Reader) (digest.Digest, error) {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := gzipReader.Close(); err != nil {
+			logrus.Warnf("error closing gzip reader: %s", err.Error())
+		}
+	}()
+	return digest.SHA256.FromReader(gzipReader)
+}
+
+// unpackPath joins a tar entry name onto the target directory, refusing entries that would escape it
+func unpackPath(directory, name string) (string, error) {
+	path := filepath.Join(directory, filepath.FromSlash(name))
+	rel, err := filepath.Rel(directory, path)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("layer entry %s points outside of %s", name, directory)
+	}
+	return path, nil
+}
+
+func unpackFile(path string, r io.Reader, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			logrus.Warnf("error closing file: %s", err.Error())
+		}
+	}()
+
+	_, err = io.Copy(file, r)
+	return err
+}
@@ -0,0 +1,161 @@
+package layer
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// layerWriter writes entries into a layer tar, creating each parent directory once
+type layerWriter struct {
+	*tar.Writer
+
+	layer *Layer
+	// root is the directory the layer is built from, its metadata is used for generated entries
+	root    os.FileInfo
+	written map[string]bool
+}
+
+func newLayerWriter(writer *tar.Writer, layer *Layer, root os.FileInfo) *layerWriter {
+	return &layerWriter{
+		Writer:  writer,
+		layer:   layer,
+		root:    root,
+		written: map[string]bool{},
+	}
+}
+
+// writeHeader writes a header, normalizing it if the layer is reproducible
+func (w *layerWriter) writeHeader(header *tar.Header) error {
+	name := strings.TrimSuffix(header.Name, "/")
+	if w.written[name] {
+		return fmt.Errorf("%s written to layer more than once", name)
+	}
+	if w.layer.Reproducible {
+		normalizeHeader(header, w.layer.Epoch)
+	}
+	if err := w.WriteHeader(header); err != nil {
+		return err
+	}
+	w.written[name] = true
+	return nil
+}
+
+// writeDirs writes a directory entry for dir and each of its parents that has not been written yet
+func (w *layerWriter) writeDirs(dir string) error {
+	if dir == "" || dir == "." {
+		return nil
+	}
+	current := ""
+	for _, elem := range strings.Split(dir, "/") {
+		current = path.Join(current, elem)
+		if w.written[current] {
+			continue
+		}
+		info, err := w.dirInfo(current)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = current + "/"
+		if err := w.writeHeader(header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dirInfo returns the metadata of the source directory of a directory in the layer
+// directories of the prefix, and of generated files that aren't in the source directory, take the root's
+func (w *layerWriter) dirInfo(name string) (os.FileInfo, error) {
+	rel := name
+	if prefix := cleanPath(w.layer.Prefix); prefix != "" {
+		if !strings.HasPrefix(name, prefix+"/") {
+			return w.root, nil
+		}
+		rel = strings.TrimPrefix(name, prefix+"/")
+	}
+	info, err := os.Stat(filepath.Join(w.layer.directory, filepath.FromSlash(rel)))
+	if os.IsNotExist(err) || (err == nil && !info.IsDir()) {
+		return w.root, nil
+	}
+	return info, err
+}
+
+// writeParents writes the directories containing name that have not been written yet
+func (w *layerWriter) writeParents(name string) error {
+	return w.writeDirs(path.Dir(name))
+}
+
+// writeFiles writes generated files, in order of their paths
+func (w *layerWriter) writeFiles(files map[string][]byte) error {
+	var paths []string
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		name := cleanPath(p)
+		if err := w.writeParents(name); err != nil {
+			return err
+		}
+		content := files[p]
+		if err := w.writeHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			ModTime:  w.root.ModTime(),
+		}); err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, bytes.NewReader(content)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// layerPath returns the slash-separated path of a file in the layer
+func layerPath(prefix, rel string) string {
+	return path.Join(prefix, filepath.ToSlash(rel))
+}
+
+// cleanPath normalizes a path in the layer to be relative and slash-separated, without a trailing slash
+func cleanPath(p string) string {
+	return strings.Trim(path.Clean("/"+filepath.ToSlash(p)), "/")
+}
+
+// normalizeHeader strips the metadata from a header that differs between machines and checkouts
+func normalizeHeader(header *tar.Header, epoch time.Time) {
+	header.Uid = 0
+	header.Gid = 0
+	header.Uname = ""
+	header.Gname = ""
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	header.PAXRecords = nil
+
+	// only the executable bit survives most checkouts, so permissions are reduced to it
+	if header.Typeflag == tar.TypeDir || header.Mode&0111 != 0 {
+		header.Mode = 0755
+	} else {
+		header.Mode = 0644
+	}
+
+	if header.ModTime.After(epoch) {
+		header.ModTime = epoch
+	}
+	header.ModTime = header.ModTime.Truncate(time.Second)
+}
@@ -0,0 +1,149 @@
+package layer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeTree writes files, by slash-separated path, into a new temporary directory
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "bndlr-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// build writes a layer of a directory and returns the compressed blob
+func build(t *testing.T, dir string, opts ...LayerOption) (*Layer, []byte) {
+	t.Helper()
+	l, err := LayerFromDirectory(dir, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := l.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return l, buf.Bytes()
+}
+
+// headers returns the tar headers of a compressed layer, in order
+func headers(t *testing.T, blob []byte) []*tar.Header {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(blob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := tar.NewReader(gz)
+	var hdrs []*tar.Header
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return hdrs
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		hdrs = append(hdrs, header)
+	}
+}
+
+func TestNestedDirectories(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"a.yaml":          "a",
+		"sub/b.yaml":      "b",
+		"sub/deep/c.yaml": "c",
+	})
+	times := map[string]time.Time{
+		".":        time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC),
+		"sub":      time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC),
+		"sub/deep": time.Date(2003, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(dir, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, mtime := range times {
+		if err := os.Chtimes(filepath.Join(dir, filepath.FromSlash(name)), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, blob := build(t, dir, WithPrefix("manifests"), WithFile("generated/metadata.yaml", []byte("generated")))
+
+	type entry struct {
+		name    string
+		mode    int64
+		modTime time.Time
+	}
+	var got []entry
+	for _, h := range headers(t, blob) {
+		e := entry{name: h.Name}
+		if h.Typeflag == tar.TypeDir {
+			e.mode, e.modTime = h.Mode&0777, h.ModTime.UTC()
+		}
+		got = append(got, e)
+	}
+	want := []entry{
+		{"manifests/", 0755, times["."]},
+		{name: "manifests/a.yaml"},
+		{"manifests/sub/", 0700, times["sub"]},
+		{name: "manifests/sub/b.yaml"},
+		{"manifests/sub/deep/", 0755, times["sub/deep"]},
+		{name: "manifests/sub/deep/c.yaml"},
+		{"generated/", 0755, times["."]},
+		{name: "generated/metadata.yaml"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected entries %+v, got %+v", want, got)
+	}
+
+	out, err := ioutil.TempDir("", "bndlr-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(out)
+	diffID, err := UnpackLayer(bytes.NewReader(blob), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffID != l.Digest {
+		t.Errorf("unpacked digest %s doesn't match built digest %s", diffID, l.Digest)
+	}
+	for name, content := range map[string]string{
+		"manifests/a.yaml":          "a",
+		"manifests/sub/b.yaml":      "b",
+		"manifests/sub/deep/c.yaml": "c",
+		"generated/metadata.yaml":   "generated",
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(out, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("unpacked %s has %q, expected %q", name, data, content)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package manifest
+
+import (
+	"fmt"
+
+	"github.com/containerd/containerd/images"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Format selects the family of media types used for manifests, configs and layers
+type Format string
+
+const (
+	// DockerFormat uses docker v2-2 media types
+	DockerFormat Format = "docker"
+	// OCIFormat uses oci image media types
+	OCIFormat Format = "oci"
+)
+
+// ParseFormat returns the Format with the given name
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case DockerFormat, OCIFormat:
+		return f, nil
+	default:
+		return "", fmt.Errorf("format %s not supported. Options: %s, %s", name, DockerFormat, OCIFormat)
+	}
+}
+
+// LayerMediaType returns the media type of a gzipped tar layer in this format
+func (f Format) LayerMediaType() string {
+	if f == OCIFormat {
+		return ocispec.MediaTypeImageLayerGzip
+	}
+	return images.MediaTypeDockerSchema2LayerGzip
+}
@@ -2,6 +2,7 @@ package manifest
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/containerd/containerd/images"
 	"github.com/opencontainers/go-digest"
@@ -32,33 +33,84 @@ func (f ConfigDescriptorFunc) MakeConfig(digests []digest.Digest) ([]byte, ocisp
 	return f(digests)
 }
 
-// A LayerDescriptor can create an image config given a list of digests
+// A LayerDescriptor can create the manifest entry for a layer given the descriptor of its stored blob
 type LayerDescriptor interface {
-	MakeDescriptor(l layer.Layer) ([]byte, ocispec.Descriptor, error)
+	MakeDescriptor(l layer.Layer, blob ocispec.Descriptor) (ocispec.Descriptor, error)
 }
 
-type LayerDescriptorFunc func(l layer.Layer) ([]byte, ocispec.Descriptor, error)
+type LayerDescriptorFunc func(l layer.Layer, blob ocispec.Descriptor) (ocispec.Descriptor, error)
 
-func (f LayerDescriptorFunc) MakeDescriptor(l layer.Layer) ([]byte, ocispec.Descriptor, error) {
-	return f(l)
+func (f LayerDescriptorFunc) MakeDescriptor(l layer.Layer, blob ocispec.Descriptor) (ocispec.Descriptor, error) {
+	return f(l, blob)
 }
 
 var _ ManifestDescriptorFunc = NewV22Manifest
+var _ ManifestDescriptorFunc = NewOCIManifest
 var _ ConfigDescriptorFunc = NewMinimalV22Config
+var _ ConfigDescriptorFunc = NewMinimalOCIConfig
 var _ LayerDescriptorFunc = NewLayerDescriptor
 
 // NewV22Manifest returns a valid v2-2 manifest given a config and layers
 func NewV22Manifest(config ocispec.Descriptor, layers []ocispec.Descriptor) ([]byte, ocispec.Descriptor, error) {
+	return NewV22ManifestDescriptor()(config, layers)
+}
+
+// NewV22ManifestDescriptor returns a ManifestDescriptorFunc that generates v2-2 manifests with options applied
+func NewV22ManifestDescriptor(opts ...ManifestOption) ManifestDescriptorFunc {
+	return func(config ocispec.Descriptor, layers []ocispec.Descriptor) ([]byte, ocispec.Descriptor, error) {
+		return newManifest(images.MediaTypeDockerSchema2Manifest, config, layers, opts...)
+	}
+}
+
+// NewOCIManifest returns a valid oci image manifest given a config and layers
+func NewOCIManifest(config ocispec.Descriptor, layers []ocispec.Descriptor) ([]byte, ocispec.Descriptor, error) {
+	return NewOCIManifestDescriptor()(config, layers)
+}
+
+// NewOCIManifestDescriptor returns a ManifestDescriptorFunc that generates oci image manifests with options applied
+func NewOCIManifestDescriptor(opts ...ManifestOption) ManifestDescriptorFunc {
+	return func(config ocispec.Descriptor, layers []ocispec.Descriptor) ([]byte, ocispec.Descriptor, error) {
+		return newManifest(ocispec.MediaTypeImageManifest, config, layers, opts...)
+	}
+}
+
+// A ManifestOption sets optional fields on a generated manifest
+type ManifestOption func(manifest *ocispec.Manifest)
+
+// WithAnnotations adds annotations to the manifest, replacing existing annotations with the same keys
+// i.e. org.opencontainers.image.source and org.opencontainers.image.revision
+func WithAnnotations(annotations map[string]string) ManifestOption {
+	return func(manifest *ocispec.Manifest) {
+		if len(annotations) == 0 {
+			return
+		}
+		if manifest.Annotations == nil {
+			manifest.Annotations = map[string]string{}
+		}
+		for k, v := range annotations {
+			manifest.Annotations[k] = v
+		}
+	}
+}
+
+func newManifest(mediaType string, config ocispec.Descriptor, layers []ocispec.Descriptor, opts ...ManifestOption) ([]byte, ocispec.Descriptor, error) {
+	fields := ocispec.Manifest{Config: config, Layers: layers}
+	for _, opt := range opts {
+		opt(&fields)
+	}
+
 	manifest := struct {
 		SchemaVersion int                  `json:"schemaVersion"`
 		MediaType     string               `json:"mediaType"`
 		Config        ocispec.Descriptor   `json:"config"`
 		Layers        []ocispec.Descriptor `json:"layers"`
+		Annotations   map[string]string    `json:"annotations,omitempty"`
 	}{
 		SchemaVersion: 2,
-		MediaType:     images.MediaTypeDockerSchema2Manifest,
-		Config:        config,
-		Layers:        layers,
+		MediaType:     mediaType,
+		Config:        fields.Config,
+		Layers:        fields.Layers,
+		Annotations:   fields.Annotations,
 	}
 
 	manifestBytes, err := json.Marshal(manifest)
@@ -66,15 +118,67 @@ func NewV22Manifest(config ocispec.Descriptor, layers []ocispec.Descriptor) ([]b
 		return nil, ocispec.Descriptor{}, err
 	}
 	manifestDescriptor := ocispec.Descriptor{
-		MediaType: images.MediaTypeDockerSchema2Manifest,
+		MediaType: mediaType,
 		Digest:    digest.FromBytes(manifestBytes),
 		Size:      int64(len(manifestBytes)),
 	}
 	return manifestBytes, manifestDescriptor, nil
 }
 
+// A ConfigOption sets optional fields on a generated image config
+type ConfigOption func(config *ocispec.Image)
+
+// WithCreated sets the creation time of the image and its history entry
+// builds that should be reproducible should pass a fixed time, i.e. image.SourceDateEpoch()
+func WithCreated(created time.Time) ConfigOption {
+	return func(config *ocispec.Image) {
+		config.Created = &created
+		for i := range config.History {
+			config.History[i].Created = &created
+		}
+	}
+}
+
+// WithLabels adds labels to the image config, replacing existing labels with the same keys
+func WithLabels(labels map[string]string) ConfigOption {
+	return func(config *ocispec.Image) {
+		if len(labels) == 0 {
+			return
+		}
+		if config.Config.Labels == nil {
+			config.Config.Labels = map[string]string{}
+		}
+		for k, v := range labels {
+			config.Config.Labels[k] = v
+		}
+	}
+}
+
 // NewV22Config returns a minimal v2-2 config manifest. `digests` contain the digests of the uncompressed layers.
 func NewMinimalV22Config(digests []digest.Digest) ([]byte, ocispec.Descriptor, error) {
+	return NewMinimalV22ConfigDescriptor()(digests)
+}
+
+// NewMinimalV22ConfigDescriptor returns a ConfigDescriptorFunc that generates minimal v2-2 configs with options applied
+func NewMinimalV22ConfigDescriptor(opts ...ConfigOption) ConfigDescriptorFunc {
+	return func(digests []digest.Digest) ([]byte, ocispec.Descriptor, error) {
+		return newMinimalConfig(images.MediaTypeDockerSchema2Config, digests, opts...)
+	}
+}
+
+// NewMinimalOCIConfig returns a minimal oci image config. `digests` contain the digests of the uncompressed layers.
+func NewMinimalOCIConfig(digests []digest.Digest) ([]byte, ocispec.Descriptor, error) {
+	return NewMinimalOCIConfigDescriptor()(digests)
+}
+
+// NewMinimalOCIConfigDescriptor returns a ConfigDescriptorFunc that generates minimal oci configs with options applied
+func NewMinimalOCIConfigDescriptor(opts ...ConfigOption) ConfigDescriptorFunc {
+	return func(digests []digest.Digest) ([]byte, ocispec.Descriptor, error) {
+		return newMinimalConfig(ocispec.MediaTypeImageConfig, digests, opts...)
+	}
+}
+
+func newMinimalConfig(mediaType string, digests []digest.Digest, opts ...ConfigOption) ([]byte, ocispec.Descriptor, error) {
 	// Config Descriptor describes the content
 	// Includes DiffIDs for docker compatibility
 	imgconfig := ocispec.Image{
@@ -94,27 +198,26 @@ func NewMinimalV22Config(digests []digest.Digest) ([]byte, ocispec.Descriptor, e
 			},
 		},
 	}
+	for _, opt := range opts {
+		opt(&imgconfig)
+	}
 
 	configBytes, err := json.Marshal(imgconfig)
 	if err != nil {
 		return nil, ocispec.Descriptor{}, err
 	}
 	return configBytes, ocispec.Descriptor{
-		MediaType: images.MediaTypeDockerSchema2Config,
+		MediaType: mediaType,
 		Digest:    digest.FromBytes(configBytes),
 		Size:      int64(len(configBytes)),
 	}, nil
 }
 
-func NewLayerDescriptor(l layer.Layer) ([]byte, ocispec.Descriptor, error) {
-	desc := ocispec.Descriptor{
-		MediaType:   l.MediaType,
-		Digest:      digest.FromBytes(l.Blob),
-		Size:        int64(len(l.Blob)),
-	}
-	layerBytes, err := json.Marshal(desc)
-	if err != nil {
-		return nil, ocispec.Descriptor{}, err
-	}
-	return layerBytes, desc, nil
+// NewLayerDescriptor describes a layer by the digest and size of its stored blob and the layer's media type
+func NewLayerDescriptor(l layer.Layer, blob ocispec.Descriptor) (ocispec.Descriptor, error) {
+	return ocispec.Descriptor{
+		MediaType: l.MediaType,
+		Digest:    blob.Digest,
+		Size:      blob.Size,
+	}, nil
 }
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/docker/cli/cli/config/configfile"
+	"github.com/docker/cli/cli/config/credentials"
+	"github.com/docker/cli/cli/config/types"
+	"github.com/docker/docker-credential-helpers/client"
+	helpers "github.com/docker/docker-credential-helpers/credentials"
+)
+
+const (
+	// dockerHubServer is the key docker uses for docker hub credentials
+	dockerHubServer = "https://index.docker.io/v1/"
+	// helperPrefix is prepended to credsStore and credHelpers values to find the helper binary
+	helperPrefix = "docker-credential-"
+	// tokenUsername is the username credential helpers report for identity tokens
+	tokenUsername = "<token>"
+)
+
+// An Error is returned when an auth file can't be loaded or credentials can't be looked up
+type Error struct {
+	// Source is the auth file or credential helper that failed
+	Source string
+	// Host is the registry credentials were requested for, empty when loading a file
+	Host string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Host == "" {
+		return fmt.Sprintf("error loading credentials from %s: %v", e.Source, e.Err)
+	}
+	return fmt.Sprintf("error getting credentials for %s from %s: %v", e.Host, e.Source, e.Err)
+}
+
+// Credentials resolves registry credentials from docker and podman auth files
+// files are consulted in order, and the first with credentials for a host wins
+// files with credsStore or credHelpers delegate to docker-credential-<name> helpers
+type Credentials struct {
+	files []*configfile.ConfigFile
+	// primary is the file credentials are stored in
+	primary *configfile.ConfigFile
+
+	mu    sync.Mutex
+	cache map[string]types.AuthConfig
+}
+
+// NewCredentials loads auth files. paths may start with `~`, and missing files are skipped
+// credentials are stored in the first file that exists, or the first path if none do
+func NewCredentials(paths ...string) (*Credentials, error) {
+	c := &Credentials{cache: map[string]types.AuthConfig{}}
+	for _, path := range paths {
+		file, exists, err := loadFile(path)
+		if err != nil {
+			return nil, &Error{Source: path, Err: err}
+		}
+		if exists {
+			c.files = append(c.files, file)
+		}
+		if c.primary == nil || (exists && len(c.files) == 1) {
+			c.primary = file
+		}
+	}
+	return c, nil
+}
+
+// loadFile reads an auth file, returning an empty file for the path if it doesn't exist
+func loadFile(path string) (*configfile.ConfigFile, bool, error) {
+	path, err := ExpandPath(path)
+	if err != nil {
+		return nil, false, err
+	}
+	file := configfile.New(path)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return file, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	if err := file.LoadFromReader(f); err != nil {
+		return nil, false, err
+	}
+	return file, true, nil
+}
+
+// Credential returns the username and secret for a registry host, or empty strings if there are none
+// an empty username with a secret is an identity token. it can be passed to docker.WithAuthCreds
+func (c *Credentials) Credential(host string) (string, string, error) {
+	auth, err := c.lookup(host)
+	if err != nil {
+		return "", "", err
+	}
+	if auth.IdentityToken != "" {
+		return "", auth.IdentityToken, nil
+	}
+	return auth.Username, auth.Password, nil
+}
+
+func (c *Credentials) lookup(host string) (types.AuthConfig, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if auth, ok := c.cache[host]; ok {
+		return auth, nil
+	}
+	server := ServerAddress(host)
+	for _, file := range c.files {
+		auth, found, err := get(file, server)
+		if err != nil {
+			return types.AuthConfig{}, &Error{Source: source(file, server), Host: host, Err: err}
+		}
+		if found {
+			c.cache[host] = auth
+			return auth, nil
+		}
+	}
+	c.cache[host] = types.AuthConfig{}
+	return types.AuthConfig{}, nil
+}
+
+// get looks up credentials for a server in a single auth file
+func get(file *configfile.ConfigFile, server string) (types.AuthConfig, bool, error) {
+	if helper := helperFor(file, server); helper != "" {
+		creds, err := client.Get(client.NewShellProgramFunc(helperPrefix+helper), server)
+		if helpers.IsErrCredentialsNotFound(err) {
+			return types.AuthConfig{}, false, nil
+		}
+		if err != nil {
+			return types.AuthConfig{}, false, err
+		}
+		auth := types.AuthConfig{ServerAddress: server}
+		if creds.Username == tokenUsername {
+			auth.IdentityToken = creds.Secret
+		} else {
+			auth.Username = creds.Username
+			auth.Password = creds.Secret
+		}
+		return auth, true, nil
+	}
+
+	// the entry login writes wins over older entries for the same host, i.e. `https://host/v1/`
+	if auth, ok := file.AuthConfigs[server]; ok && hasCredentials(auth) {
+		return auth, true, nil
+	}
+	keys := make([]string, 0, len(file.AuthConfigs))
+	for key := range file.AuthConfigs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if auth := file.AuthConfigs[key]; ServerAddress(credentials.ConvertToHostname(key)) == server && hasCredentials(auth) {
+			return auth, true, nil
+		}
+	}
+	return types.AuthConfig{}, false, nil
+}
+
+func hasCredentials(auth types.AuthConfig) bool {
+	return auth.Username != "" || auth.Password != "" || auth.IdentityToken != ""
+}
+
+// helperFor returns the name of the credential helper a file uses for a server, if any
+func helperFor(file *configfile.ConfigFile, server string) string {
+	if helper, ok := file.CredentialHelpers[server]; ok {
+		return helper
+	}
+	return file.CredentialsStore
+}
+
+// source describes where credentials for a server in a file come from, for errors
+func source(file *configfile.ConfigFile, server string) string {
+	if helper := helperFor(file, server); helper != "" {
+		return helperPrefix + helper
+	}
+	return file.Filename
+}
+
+// ServerAddress returns the key credentials for a registry host are stored under
+// docker hub credentials are stored under its v1 index url, everything else by host
+func ServerAddress(host string) string {
+	switch host {
+	case "docker.io", "index.docker.io", "registry-1.docker.io", dockerHubServer:
+		return dockerHubServer
+	}
+	return host
+}
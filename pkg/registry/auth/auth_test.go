@@ -0,0 +1,256 @@
+package auth_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ecordell/bndlr/pkg/registry/auth"
+)
+
+// setenv sets an environment variable for the rest of the test, unsetting it if value is empty
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	previous, ok := os.LookupEnv(key)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+	if value == "" {
+		os.Unsetenv(key)
+		return
+	}
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "bndlr-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// fakeHelpers puts docker-credential-<name> helpers on PATH that report their own name as the username
+// they know registry.example.com and token.example.com, and the helper named broken always fails
+func fakeHelpers(t *testing.T, names ...string) {
+	t.Helper()
+	dir := tempDir(t)
+	for _, name := range names {
+		script := fmt.Sprintf(`#!/bin/sh
+[ "$1" = get ] || exit 1
+read server
+case "%[1]s:$server" in
+broken:*) echo "keychain is locked"; exit 1 ;;
+*:registry.example.com) echo '{"ServerURL":"registry.example.com","Username":"%[1]s","Secret":"%[1]s-secret"}' ;;
+*:token.example.com) echo '{"ServerURL":"token.example.com","Username":"<token>","Secret":"%[1]s-token"}' ;;
+*) echo "credentials not found in native keychain"; exit 1 ;;
+esac
+`, name)
+		if err := ioutil.WriteFile(filepath.Join(dir, "docker-credential-"+name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setenv(t, "PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestCredentialHelpers(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        []string
+		host         string
+		wantUsername string
+		wantSecret   string
+		wantErr      string
+	}{
+		{
+			name:         "credsStore",
+			files:        []string{`{"credsStore":"store"}`},
+			host:         "registry.example.com",
+			wantUsername: "store",
+			wantSecret:   "store-secret",
+		},
+		{
+			name:         "credHelpers override credsStore for their host",
+			files:        []string{`{"credsStore":"store","credHelpers":{"registry.example.com":"host"}}`},
+			host:         "registry.example.com",
+			wantUsername: "host",
+			wantSecret:   "host-secret",
+		},
+		{
+			name:         "helpers override auths",
+			files:        []string{`{"auths":{"registry.example.com":{"auth":"ZmlsZTpwYXNz"}},"credsStore":"store"}`},
+			host:         "registry.example.com",
+			wantUsername: "store",
+			wantSecret:   "store-secret",
+		},
+		{
+			name:         "auths without a helper",
+			files:        []string{`{"auths":{"registry.example.com":{"auth":"ZmlsZTpwYXNz"}},"credHelpers":{"other.example.com":"host"}}`},
+			host:         "registry.example.com",
+			wantUsername: "file",
+			wantSecret:   "pass",
+		},
+		{
+			name:       "identity token",
+			files:      []string{`{"credsStore":"store"}`},
+			host:       "token.example.com",
+			wantSecret: "store-token",
+		},
+		{
+			name:  "helper without credentials",
+			files: []string{`{"credsStore":"store"}`},
+			host:  "missing.example.com",
+		},
+		{
+			name:         "first file with credentials wins",
+			files:        []string{`{"credsStore":"store"}`, `{"credHelpers":{"registry.example.com":"host"}}`},
+			host:         "registry.example.com",
+			wantUsername: "store",
+			wantSecret:   "store-secret",
+		},
+		{
+			name:         "later files are consulted when a helper has no credentials",
+			files:        []string{`{"credsStore":"store"}`, `{"auths":{"missing.example.com":{"auth":"ZmlsZTpwYXNz"}}}`},
+			host:         "missing.example.com",
+			wantUsername: "file",
+			wantSecret:   "pass",
+		},
+		{
+			name:    "failing helper",
+			files:   []string{`{"credsStore":"broken"}`},
+			host:    "registry.example.com",
+			wantErr: "error getting credentials for registry.example.com from docker-credential-broken",
+		},
+		{
+			name:    "missing helper",
+			files:   []string{`{"credHelpers":{"registry.example.com":"missing"}}`},
+			host:    "registry.example.com",
+			wantErr: "from docker-credential-missing",
+		},
+	}
+	fakeHelpers(t, "store", "host", "broken")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tempDir(t)
+			var paths []string
+			for i, file := range tt.files {
+				path := filepath.Join(dir, fmt.Sprintf("config-%d.json", i))
+				if err := ioutil.WriteFile(path, []byte(file), 0600); err != nil {
+					t.Fatal(err)
+				}
+				paths = append(paths, path)
+			}
+			creds, err := auth.NewCredentials(paths...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			username, secret, err := creds.Credential(tt.host)
+			if tt.wantErr != "" {
+				if _, ok := err.(*auth.Error); !ok || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an *auth.Error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if username != tt.wantUsername || secret != tt.wantSecret {
+				t.Errorf("expected %s:%s, got %s:%s", tt.wantUsername, tt.wantSecret, username, secret)
+			}
+		})
+	}
+}
+
+func TestDefaultFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "defaults",
+			want: []string{"~/.config/containers/auth.json", "~/.docker/config.json"},
+		},
+		{
+			name: "REGISTRY_AUTH_FILE replaces every other file",
+			env: map[string]string{
+				auth.RegistryAuthFileEnv: "/etc/auth.json",
+				"XDG_RUNTIME_DIR":        "/run/user/1000",
+				"DOCKER_CONFIG":          "/etc/docker",
+			},
+			want: []string{"/etc/auth.json"},
+		},
+		{
+			name: "xdg and docker config directories",
+			env: map[string]string{
+				"XDG_RUNTIME_DIR": "/run/user/1000",
+				"XDG_CONFIG_HOME": "/home/user/.config",
+				"DOCKER_CONFIG":   "/etc/docker",
+			},
+			want: []string{"/run/user/1000/containers/auth.json", "/home/user/.config/containers/auth.json", "/etc/docker/config.json"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{auth.RegistryAuthFileEnv, "XDG_RUNTIME_DIR", "XDG_CONFIG_HOME", "DOCKER_CONFIG"} {
+				setenv(t, key, tt.env[key])
+			}
+			if got := auth.DefaultFiles(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	home := tempDir(t)
+	setenv(t, "HOME", home)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "~", want: home},
+		{path: "~/.docker/config.json", want: filepath.Join(home, ".docker", "config.json")},
+		{path: "~user/config.json", want: "~user/config.json"},
+		{path: "/etc/auth.json", want: "/etc/auth.json"},
+		{path: "auth.json", want: "auth.json"},
+	}
+	for _, tt := range tests {
+		got, err := auth.ExpandPath(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("ExpandPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	// auth files are read from the expanded path
+	if err := os.MkdirAll(filepath.Join(home, ".docker"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(home, ".docker", "config.json"), []byte(`{"auths":{"registry.example.com":{"auth":"ZmlsZTpwYXNz"}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	creds, err := auth.NewCredentials("~/.docker/config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username, password, err := creds.Credential("registry.example.com"); err != nil || username != "file" || password != "pass" {
+		t.Errorf("expected file:pass from the expanded path, got %s:%s (%v)", username, password, err)
+	}
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RegistryAuthFileEnv overrides the default auth files, as it does for podman and skopeo
+const RegistryAuthFileEnv = "REGISTRY_AUTH_FILE"
+
+// ExpandPath replaces a leading `~` with the user's home directory
+func ExpandPath(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
+
+// DefaultFiles returns the auth files to read when none are given
+// $REGISTRY_AUTH_FILE if it is set, otherwise podman's auth.json followed by docker's config.json
+func DefaultFiles() []string {
+	if path := os.Getenv(RegistryAuthFileEnv); path != "" {
+		return []string{path}
+	}
+
+	var paths []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		paths = append(paths, filepath.Join(dir, "containers", "auth.json"))
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		paths = append(paths, filepath.Join(dir, "containers", "auth.json"))
+	} else {
+		paths = append(paths, "~/.config/containers/auth.json")
+	}
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		paths = append(paths, filepath.Join(dir, "config.json"))
+	} else {
+		paths = append(paths, "~/.docker/config.json")
+	}
+	return paths
+}
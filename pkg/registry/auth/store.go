@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/docker/cli/cli/config/configfile"
+	"github.com/docker/cli/cli/config/credentials"
+	"github.com/docker/cli/cli/config/types"
+	"github.com/docker/docker-credential-helpers/client"
+	helpers "github.com/docker/docker-credential-helpers/credentials"
+)
+
+// Store saves credentials for a registry host in the primary auth file,
+// or in the credential helper the file configures for the host
+// it returns where the credentials were stored
+func (c *Credentials) Store(host, username, password string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.primary == nil {
+		return "", fmt.Errorf("no auth file configured")
+	}
+	server := ServerAddress(host)
+	delete(c.cache, host)
+
+	if helper := helperFor(c.primary, server); helper != "" {
+		err := client.Store(client.NewShellProgramFunc(helperPrefix+helper), &helpers.Credentials{
+			ServerURL: server,
+			Username:  username,
+			Secret:    password,
+		})
+		if err != nil {
+			return "", &Error{Source: helperPrefix + helper, Host: host, Err: err}
+		}
+		return helperPrefix + helper, nil
+	}
+
+	c.primary.AuthConfigs[server] = types.AuthConfig{
+		Username:      username,
+		Password:      password,
+		ServerAddress: server,
+	}
+	if err := c.primary.Save(); err != nil {
+		return "", &Error{Source: c.primary.Filename, Host: host, Err: err}
+	}
+	return c.primary.Filename, nil
+}
+
+// Erase removes credentials for a registry host from every auth file and the credential helpers they configure
+// it returns where credentials were removed from, which is empty if there were none
+func (c *Credentials) Erase(host string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	server := ServerAddress(host)
+	delete(c.cache, host)
+
+	var erased []string
+	for _, file := range c.files {
+		sources, err := erase(file, server)
+		erased = append(erased, sources...)
+		if err != nil {
+			return erased, &Error{Source: source(file, server), Host: host, Err: err}
+		}
+	}
+	return erased, nil
+}
+
+// erase removes credentials for a server from a single auth file, and its credential helper
+func erase(file *configfile.ConfigFile, server string) ([]string, error) {
+	var erased []string
+	if helper := helperFor(file, server); helper != "" {
+		// helpers don't report missing credentials on erase, so check first
+		program := client.NewShellProgramFunc(helperPrefix + helper)
+		_, err := client.Get(program, server)
+		if err != nil && !helpers.IsErrCredentialsNotFound(err) {
+			return erased, err
+		}
+		if err == nil {
+			if err := client.Erase(program, server); err != nil {
+				return erased, err
+			}
+			erased = append(erased, helperPrefix+helper)
+		}
+	}
+
+	removed := false
+	for key := range file.AuthConfigs {
+		if key == server || ServerAddress(credentials.ConvertToHostname(key)) == server {
+			delete(file.AuthConfigs, key)
+			removed = true
+		}
+	}
+	if !removed {
+		return erased, nil
+	}
+	if err := file.Save(); err != nil {
+		return erased, err
+	}
+	return append(erased, file.Filename), nil
+}
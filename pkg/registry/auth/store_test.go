@@ -0,0 +1,80 @@
+package auth_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ecordell/bndlr/pkg/registry/auth"
+)
+
+func TestStoreAndErase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bndlr-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	podman := filepath.Join(dir, "auth.json")
+	docker := filepath.Join(dir, "config.json")
+	const host = "registry.example.com"
+
+	// docker's file exists with credentials for the host and another registry, podman's doesn't exist yet
+	if err := ioutil.WriteFile(docker, []byte(`{"auths":{
+		"https://registry.example.com/v1/":{"auth":"b2xkOnBhc3M="},
+		"other.example.com":{"auth":"b3RoZXI6cGFzcw=="}
+	}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := auth.NewCredentials(podman, docker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := creds.Store(host, "alice", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != docker {
+		t.Errorf("expected credentials to be stored in the existing file %s, got %s", docker, stored)
+	}
+
+	creds, err = auth.NewCredentials(podman, docker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username, password, err := creds.Credential(host); err != nil || username != "alice" || password != "s3cret" {
+		t.Fatalf("expected stored credentials alice:s3cret, got %s:%s (%v)", username, password, err)
+	}
+
+	erased, err := creds.Erase(host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(erased, []string{docker}) {
+		t.Errorf("expected credentials to be erased from %s, got %v", docker, erased)
+	}
+	data, err := ioutil.ReadFile(docker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "registry.example.com") {
+		t.Errorf("expected every entry for the host to be removed, got %s", data)
+	}
+	if !strings.Contains(string(data), "other.example.com") {
+		t.Errorf("expected other registries to be kept, got %s", data)
+	}
+
+	creds, err = auth.NewCredentials(podman, docker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username, password, err := creds.Credential(host); err != nil || username != "" || password != "" {
+		t.Errorf("expected no credentials after logging out, got %s:%s (%v)", username, password, err)
+	}
+	if erased, err := creds.Erase(host); err != nil || len(erased) != 0 {
+		t.Errorf("expected logging out twice to erase nothing, got %v (%v)", erased, err)
+	}
+}
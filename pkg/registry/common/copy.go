@@ -0,0 +1,65 @@
+package common
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/containerd/containerd/reference"
+	"github.com/containerd/containerd/remotes"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/ecordell/bndlr/pkg/image"
+	"github.com/ecordell/bndlr/pkg/registry/store"
+)
+
+// distributionSourceLabel is the annotation prefix the docker pusher reads to mount blobs from another repository on the same registry
+const distributionSourceLabel = "containerd.io/distribution.source."
+
+// PullForCopy pulls the image at src into the store, to be pushed verbatim with PushToRefs
+// its blobs are annotated with their source repository, so that pushes to the same registry mount them
+func PullForCopy(ctx context.Context, s store.Store, resolver remotes.Resolver, src string) (*image.Descriptor, error) {
+	spec, err := reference.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rejectManifestList(ctx, resolver, src); err != nil {
+		return nil, err
+	}
+
+	pulled, err := s.Pull(ctx, resolver, src)
+	if err != nil {
+		return nil, err
+	}
+	return withMountSource(pulled, spec.Locator), nil
+}
+
+// withMountSource returns a copy of an image with its blobs annotated as coming from a repository
+// the annotations are only read by the pusher and are never written into the manifest
+func withMountSource(img *image.Descriptor, locator string) *image.Descriptor {
+	u, err := url.Parse("dummy://" + locator)
+	if err != nil {
+		return img
+	}
+	key := distributionSourceLabel + u.Hostname()
+	repository := strings.TrimPrefix(u.Path, "/")
+
+	annotate := func(desc ocispec.Descriptor) ocispec.Descriptor {
+		annotations := map[string]string{key: repository}
+		for k, v := range desc.Annotations {
+			annotations[k] = v
+		}
+		desc.Annotations = annotations
+		return desc
+	}
+
+	annotated := &image.Descriptor{
+		Manifest: img.Manifest,
+		Config:   annotate(img.Config),
+	}
+	for _, l := range img.Layers {
+		annotated.Layers = append(annotated.Layers, annotate(l))
+	}
+	return annotated
+}
@@ -0,0 +1,149 @@
+package common_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/remotes"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/ecordell/bndlr/pkg/image/manifest"
+	"github.com/ecordell/bndlr/pkg/registry/common"
+	registrytesting "github.com/ecordell/bndlr/pkg/registry/testing"
+)
+
+func TestCopy(t *testing.T) {
+	r := newRegistry(t)
+	other := newRegistry(t)
+	resolver := newResolver(t, r, "", "")
+	src := r.Ref("bundle", "v1")
+	pushed, err := push(testContext(), newStore(t, "memory"), resolver, src, manifest.DockerFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, storeType := range storeTypes {
+		t.Run(storeType, func(t *testing.T) {
+			r.Reset()
+			other.Reset()
+			// a repository per store, so that no destination has the blobs yet
+			dsts := []string{r.Ref("promoted-"+storeType, "v1"), other.Ref("bundle-"+storeType, "v1")}
+
+			s := newStore(t, storeType)
+			img, err := common.PullForCopy(testContext(), s, resolver, src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the harness registries only differ by port, so one resolver reaches both
+			results, err := common.PushToRefs(testContext(), s, resolver, img, dsts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, result := range results {
+				if result.Err != nil {
+					t.Errorf("%s: %v", result.Ref, result.Err)
+				}
+				if result.Digest != *pushed {
+					t.Errorf("%s: copied with digest %s, pushed %s", result.Ref, result.Digest, *pushed)
+				}
+			}
+
+			// blobs are mounted within the registry, and uploaded to the other one
+			if n := r.Count(http.MethodPut, "/blobs/uploads/"); n != 0 {
+				t.Errorf("uploaded %d blobs that could be mounted", n)
+			}
+			if n := r.Count(http.MethodPost, "/v2/promoted-"+storeType+"/blobs/uploads/"); n == 0 {
+				t.Error("expected blobs to be mounted into the repository")
+			}
+			if n := other.Count(http.MethodPut, "/blobs/uploads/"); n == 0 {
+				t.Error("expected blobs to be uploaded to the other registry")
+			}
+
+			for _, dst := range dsts {
+				_, desc, err := resolver.Resolve(testContext(), dst)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if desc.Digest != *pushed {
+					t.Errorf("%s resolves to %s, pushed %s", dst, desc.Digest, *pushed)
+				}
+			}
+		})
+	}
+}
+
+func TestCopyRejectsManifestList(t *testing.T) {
+	r := newRegistry(t)
+	resolver := newResolver(t, r, "", "")
+	list, pushed := pushManifestList(t, r, resolver)
+
+	r.Reset()
+	_, err := common.PullForCopy(testContext(), newStore(t, "memory"), resolver, list)
+	if err == nil || !strings.Contains(err.Error(), "manifest list") {
+		t.Fatalf("expected copying a manifest list to fail, got %v", err)
+	}
+	if n := r.Count(http.MethodGet, "/manifests/"+pushed.String()); n != 0 {
+		t.Errorf("fetched the images of the list %d times before rejecting it", n)
+	}
+	if n := r.Count(http.MethodGet, "/blobs/"); n != 0 {
+		t.Errorf("fetched %d blobs before rejecting the list", n)
+	}
+}
+
+// pushManifestList pushes the test bundle and a manifest list of it to the bundle repository of a registry
+// returns the reference of the list and the digest of the bundle's manifest
+func pushManifestList(t *testing.T, r *registrytesting.Registry, resolver remotes.Resolver) (string, digest.Digest) {
+	t.Helper()
+	pushed, err := push(testContext(), newStore(t, "memory"), resolver, r.Ref("bundle", "v1"), manifest.DockerFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     images.MediaTypeDockerSchema2ManifestList,
+		"manifests": []ocispec.Descriptor{{
+			MediaType: images.MediaTypeDockerSchema2Manifest,
+			Digest:    *pushed,
+			Size:      manifestSize(t, r, *pushed),
+			Platform:  &ocispec.Platform{OS: "linux", Architecture: "amd64"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("http://%s/v2/bundle/manifests/list", r.Host()), bytes.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", images.MediaTypeDockerSchema2ManifestList)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("putting manifest list: %s", resp.Status)
+	}
+	return r.Ref("bundle", "list"), *pushed
+}
+
+// manifestSize returns the size of a manifest in the bundle repository of a registry
+func manifestSize(t *testing.T, r *registrytesting.Registry, d digest.Digest) int64 {
+	t.Helper()
+	resp, err := http.Head(fmt.Sprintf("http://%s/v2/bundle/manifests/%s", r.Host(), d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("head manifest %s: %s", d, resp.Status)
+	}
+	return resp.ContentLength
+}
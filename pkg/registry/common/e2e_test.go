@@ -0,0 +1,352 @@
+package common_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/remotes"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/ecordell/bndlr/pkg/image/manifest"
+	"github.com/ecordell/bndlr/pkg/registry/common"
+	"github.com/ecordell/bndlr/pkg/registry/filestore"
+	"github.com/ecordell/bndlr/pkg/registry/memory"
+	"github.com/ecordell/bndlr/pkg/registry/ocilayout"
+	"github.com/ecordell/bndlr/pkg/registry/retry"
+	"github.com/ecordell/bndlr/pkg/registry/store"
+	registrytesting "github.com/ecordell/bndlr/pkg/registry/testing"
+)
+
+const bundleDir = "testdata/bundle"
+
+var storeTypes = []string{"memory", "file", "ocilayout"}
+
+var formats = []manifest.Format{manifest.DockerFormat, manifest.OCIFormat}
+
+// testContext retries quickly, so fault injection tests don't wait on the default backoff
+func testContext() context.Context {
+	return retry.WithPolicy(context.Background(), retry.Policy{
+		Attempts:       3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	})
+}
+
+func newStore(t *testing.T, storeType string) store.Store {
+	t.Helper()
+	if storeType == "memory" {
+		return memory.NewMemoryStore()
+	}
+	dir := tempDir(t)
+	var (
+		s   store.Store
+		err error
+	)
+	switch storeType {
+	case "file":
+		s, err = filestore.NewFileStore(dir)
+	case "ocilayout":
+		s, err = ocilayout.NewOCILayoutStore(dir)
+	default:
+		t.Fatalf("unknown store type %s", storeType)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "bndlr-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func newRegistry(t *testing.T, opts ...registrytesting.RegistryOption) *registrytesting.Registry {
+	t.Helper()
+	r, err := registrytesting.NewRegistry(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func newResolver(t *testing.T, r *registrytesting.Registry, username, password string) remotes.Resolver {
+	t.Helper()
+	resolver, err := r.Resolver(username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolver
+}
+
+// push builds the test bundle into a store and pushes it to ref
+func push(ctx context.Context, s store.Store, resolver remotes.Resolver, ref string, format manifest.Format) (*digest.Digest, error) {
+	if format == manifest.DockerFormat {
+		return common.BuildAndPushDirectoryV22(ctx, ref, s, resolver, bundleDir)
+	}
+	return common.BuildAndPushDirectory(ctx, ref, s, resolver, bundleDir, common.WithFormat(format))
+}
+
+// assertUnpacked checks that every file of the test bundle was unpacked into dir with the same content
+func assertUnpacked(t *testing.T, dir string) {
+	t.Helper()
+	err := filepath.Walk(bundleDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(bundleDir, path)
+		if err != nil {
+			return err
+		}
+		want, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		got, err := ioutil.ReadFile(filepath.Join(dir, rel))
+		if err != nil {
+			t.Errorf("%s wasn't unpacked: %v", rel, err)
+			return nil
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s was unpacked with different content", rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPushPull(t *testing.T) {
+	for _, format := range formats {
+		t.Run(string(format), func(t *testing.T) {
+			r := newRegistry(t)
+			resolver := newResolver(t, r, "", "")
+
+			// every store must push the same image, so switching stores never changes what gets pushed
+			digests := map[string]digest.Digest{}
+			for _, pushStore := range storeTypes {
+				ref := r.Ref("bundle", pushStore)
+				d, err := push(testContext(), newStore(t, pushStore), resolver, ref, format)
+				if err != nil {
+					t.Fatalf("push with %s store: %v", pushStore, err)
+				}
+				digests[pushStore] = *d
+
+				for _, pullStore := range storeTypes {
+					t.Run(pushStore+"-to-"+pullStore, func(t *testing.T) {
+						dir := tempDir(t)
+						pulled, err := common.PullAndUnpackDirectory(testContext(), ref, newStore(t, pullStore), resolver, dir)
+						if err != nil {
+							t.Fatalf("pull with %s store: %v", pullStore, err)
+						}
+						if pulled.Manifest.Digest != *d {
+							t.Errorf("pulled digest %s, pushed %s", pulled.Manifest.Digest, *d)
+						}
+						assertUnpacked(t, dir)
+					})
+				}
+			}
+			for storeType, d := range digests {
+				if d != digests[storeTypes[0]] {
+					t.Errorf("%s store pushed %s, %s store pushed %s", storeType, d, storeTypes[0], digests[storeTypes[0]])
+				}
+			}
+		})
+	}
+}
+
+func TestPushSkipsExistingBlobs(t *testing.T) {
+	r := newRegistry(t)
+	resolver := newResolver(t, r, "", "")
+	ref := r.Ref("bundle", "test")
+
+	if _, err := push(testContext(), newStore(t, "file"), resolver, ref, manifest.DockerFormat); err != nil {
+		t.Fatal(err)
+	}
+	r.Reset()
+	if _, err := push(testContext(), newStore(t, "memory"), resolver, ref, manifest.DockerFormat); err != nil {
+		t.Fatal(err)
+	}
+	if n := r.Count(http.MethodPut, "/blobs/uploads/"); n != 0 {
+		t.Errorf("uploaded %d blobs the registry already had", n)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []registrytesting.RegistryOption
+		username string
+		password string
+		wantErr  bool
+	}{
+		{
+			name: "anonymous",
+		},
+		{
+			name:     "basic",
+			opts:     []registrytesting.RegistryOption{registrytesting.WithBasicAuth("alice", "s3cret")},
+			username: "alice",
+			password: "s3cret",
+		},
+		{
+			name:     "basic with wrong password",
+			opts:     []registrytesting.RegistryOption{registrytesting.WithBasicAuth("alice", "s3cret")},
+			username: "alice",
+			password: "wrong",
+			wantErr:  true,
+		},
+		{
+			name:    "basic without credentials",
+			opts:    []registrytesting.RegistryOption{registrytesting.WithBasicAuth("alice", "s3cret")},
+			wantErr: true,
+		},
+		{
+			name:     "token",
+			opts:     []registrytesting.RegistryOption{registrytesting.WithTokenAuth("alice", "s3cret")},
+			username: "alice",
+			password: "s3cret",
+		},
+		{
+			name:     "token with wrong password",
+			opts:     []registrytesting.RegistryOption{registrytesting.WithTokenAuth("alice", "s3cret")},
+			username: "alice",
+			password: "wrong",
+			wantErr:  true,
+		},
+		{
+			name:    "token without credentials",
+			opts:    []registrytesting.RegistryOption{registrytesting.WithTokenAuth("alice", "s3cret")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRegistry(t, tt.opts...)
+			resolver := newResolver(t, r, tt.username, tt.password)
+			ref := r.Ref("bundle", "test")
+
+			_, err := push(testContext(), newStore(t, "memory"), resolver, ref, manifest.DockerFormat)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("push error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			dir := tempDir(t)
+			if _, err := common.PullAndUnpackDirectory(testContext(), ref, newStore(t, "memory"), resolver, dir); err != nil {
+				t.Fatalf("pull: %v", err)
+			}
+			assertUnpacked(t, dir)
+		})
+	}
+}
+
+func TestFaults(t *testing.T) {
+	tests := []struct {
+		name string
+		// pushFaults are injected before pushing, pullFaults after pushing and before pulling
+		pushFaults []registrytesting.Fault
+		pullFaults []registrytesting.Fault
+		pullRef    string
+		wantPush   bool
+		wantPull   bool
+	}{
+		{
+			name:       "transient upload failure is retried",
+			pushFaults: []registrytesting.Fault{{Method: http.MethodPut, Path: "/blobs/uploads/", Status: http.StatusBadGateway, Times: 1}},
+			wantPush:   true,
+			wantPull:   true,
+		},
+		{
+			name:       "transient manifest failure is retried",
+			pushFaults: []registrytesting.Fault{{Method: http.MethodPut, Path: "/manifests/", Status: http.StatusServiceUnavailable, Times: 1}},
+			wantPush:   true,
+			wantPull:   true,
+		},
+		{
+			name:       "rate limited manifest push is retried",
+			pushFaults: []registrytesting.Fault{{Method: http.MethodPut, Path: "/manifests/", Status: http.StatusTooManyRequests, Times: 2}},
+			wantPush:   true,
+			wantPull:   true,
+		},
+		{
+			name:       "unsupported manifest push isn't retried",
+			pushFaults: []registrytesting.Fault{{Method: http.MethodPut, Path: "/manifests/", Status: http.StatusNotImplemented, Times: 1}},
+		},
+		{
+			name:       "persistent upload failure fails the push",
+			pushFaults: []registrytesting.Fault{{Method: http.MethodPut, Path: "/blobs/uploads/", Status: http.StatusInternalServerError}},
+		},
+		{
+			name:       "rejected manifest fails the push",
+			pushFaults: []registrytesting.Fault{{Method: http.MethodPut, Path: "/manifests/", Status: http.StatusBadRequest}},
+		},
+		{
+			name:       "transient download failure is retried",
+			pullFaults: []registrytesting.Fault{{Method: http.MethodGet, Path: "/blobs/", Status: http.StatusBadGateway, Times: 1}},
+			wantPush:   true,
+			wantPull:   true,
+		},
+		{
+			name:       "corrupted blob fails the pull",
+			pullFaults: []registrytesting.Fault{{Method: http.MethodGet, Path: "/blobs/", Corrupt: true}},
+			wantPush:   true,
+		},
+		{
+			name:     "missing tag fails the pull",
+			pullRef:  "missing",
+			wantPush: true,
+		},
+	}
+	for _, tt := range tests {
+		for _, storeType := range storeTypes {
+			t.Run(tt.name+"/"+storeType, func(t *testing.T) {
+				r := newRegistry(t)
+				resolver := newResolver(t, r, "", "")
+				ref := r.Ref("bundle", "test")
+
+				for _, f := range tt.pushFaults {
+					r.Inject(f)
+				}
+				_, err := push(testContext(), newStore(t, storeType), resolver, ref, manifest.DockerFormat)
+				if (err == nil) != tt.wantPush {
+					t.Fatalf("push error = %v, want success %v", err, tt.wantPush)
+				}
+				if !tt.wantPush {
+					return
+				}
+
+				r.Reset()
+				for _, f := range tt.pullFaults {
+					r.Inject(f)
+				}
+				pullRef := ref
+				if tt.pullRef != "" {
+					pullRef = r.Ref("bundle", tt.pullRef)
+				}
+				dir := tempDir(t)
+				_, err = common.PullAndUnpackDirectory(testContext(), pullRef, newStore(t, storeType), resolver, dir)
+				if (err == nil) != tt.wantPull {
+					t.Fatalf("pull error = %v, want success %v", err, tt.wantPull)
+				}
+				if tt.wantPull {
+					assertUnpacked(t, dir)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/remotes"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/ecordell/bndlr/pkg/bundle"
+	"github.com/ecordell/bndlr/pkg/image/layer"
+	"github.com/ecordell/bndlr/pkg/registry/store"
+)
+
+// ImageInfo describes a pulled image for display
+type ImageInfo struct {
+	Ref        string             `json:"ref"`
+	Descriptor ocispec.Descriptor `json:"descriptor"`
+	Manifest   json.RawMessage    `json:"manifest"`
+	Config     ocispec.Image      `json:"config"`
+	Layers     []LayerInfo        `json:"layers"`
+	// Bundle holds the OLM bundle annotations found in the config labels, if any
+	Bundle map[string]string `json:"bundle,omitempty"`
+}
+
+// LayerInfo describes a single layer and the files in it
+type LayerInfo struct {
+	ocispec.Descriptor
+	Files []string `json:"files"`
+}
+
+// InspectImage pulls an image into the store and reads its manifest, config, and the files in each layer
+// manifest lists are rejected, only image manifests can be inspected
+func InspectImage(ctx context.Context, ref string, s store.Store, resolver remotes.Resolver) (*ImageInfo, error) {
+	if err := rejectManifestList(ctx, resolver, ref); err != nil {
+		return nil, err
+	}
+	image, err := s.Pull(ctx, resolver, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestBytes, err := content.ReadBlob(ctx, s, image.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	configBytes, err := content.ReadBlob(ctx, s, image.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ImageInfo{
+		Ref:        ref,
+		Descriptor: image.Manifest,
+		Manifest:   manifestBytes,
+	}
+	if err := json.Unmarshal(configBytes, &info.Config); err != nil {
+		return nil, err
+	}
+	if annotations := bundle.AnnotationsFromLabels(info.Config.Config.Labels); len(annotations) > 0 {
+		info.Bundle = annotations
+	}
+
+	for _, l := range image.Layers {
+		files, err := listLayerFiles(ctx, s, l)
+		if err != nil {
+			return nil, err
+		}
+		info.Layers = append(info.Layers, LayerInfo{Descriptor: l, Files: files})
+	}
+	return info, nil
+}
+
+func listLayerFiles(ctx context.Context, s store.Store, desc ocispec.Descriptor) ([]string, error) {
+	ra, err := s.ReaderAt(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer ra.Close()
+
+	return layer.ListFiles(content.NewReader(ra))
+}
@@ -0,0 +1,125 @@
+package common_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/containerd/containerd/images"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/ecordell/bndlr/pkg/bundle"
+	"github.com/ecordell/bndlr/pkg/image/manifest"
+	"github.com/ecordell/bndlr/pkg/registry/common"
+)
+
+func TestInspectImage(t *testing.T) {
+	spec, err := common.ParseLayerSpec("crds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := &bundle.Metadata{Package: "example", Channels: []string{"alpha"}, DefaultChannel: "alpha", Manifests: bundle.ManifestsDir}
+
+	manifestMediaTypes := map[manifest.Format]string{
+		manifest.DockerFormat: images.MediaTypeDockerSchema2Manifest,
+		manifest.OCIFormat:    ocispec.MediaTypeImageManifest,
+	}
+	for _, format := range formats {
+		t.Run(string(format), func(t *testing.T) {
+			r := newRegistry(t)
+			resolver := newResolver(t, r, "", "")
+			ref := r.Ref("bundle", "v1")
+			pushed, err := common.BuildAndPushDirectory(testContext(), ref, newStore(t, "memory"), resolver, bundleDir,
+				common.WithFormat(format), common.WithLayers(spec), common.WithBundleMetadata(metadata))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := common.InspectImage(testContext(), ref, newStore(t, "memory"), resolver)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Ref != ref {
+				t.Errorf("expected ref %s, got %s", ref, info.Ref)
+			}
+			if info.Descriptor.Digest != *pushed || info.Descriptor.MediaType != manifestMediaTypes[format] {
+				t.Errorf("expected a %s manifest with digest %s, got %s %s", manifestMediaTypes[format], pushed, info.Descriptor.MediaType, info.Descriptor.Digest)
+			}
+
+			var m ocispec.Manifest
+			if err := json.Unmarshal(info.Manifest, &m); err != nil {
+				t.Fatal(err)
+			}
+			if len(m.Layers) != 2 || !reflect.DeepEqual(m.Layers, descriptors(info.Layers)) {
+				t.Errorf("expected the manifest's two layers, got %v for manifest layers %v", descriptors(info.Layers), m.Layers)
+			}
+			if info.Config.OS != "linux" || len(info.Config.RootFS.DiffIDs) != 2 {
+				t.Errorf("expected a linux config with two diffIDs, got %s with %v", info.Config.OS, info.Config.RootFS.DiffIDs)
+			}
+
+			wantFiles := [][]string{
+				{"crds/", "crds/examples.example.com.crd.yaml"},
+				{"example.package.yaml", "example.v0.1.0.clusterserviceversion.yaml", "metadata/", "metadata/annotations.yaml"},
+			}
+			var files [][]string
+			for _, l := range info.Layers {
+				files = append(files, l.Files)
+			}
+			if !reflect.DeepEqual(files, wantFiles) {
+				t.Errorf("expected layer files %v, got %v", wantFiles, files)
+			}
+
+			if !reflect.DeepEqual(info.Bundle, metadata.Annotations()) {
+				t.Errorf("expected bundle annotations %v, got %v", metadata.Annotations(), info.Bundle)
+			}
+		})
+	}
+}
+
+func TestInspectImageWithoutBundle(t *testing.T) {
+	r := newRegistry(t)
+	resolver := newResolver(t, r, "", "")
+	ref := r.Ref("bundle", "v1")
+	if _, err := push(testContext(), newStore(t, "memory"), resolver, ref, formats[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := common.InspectImage(testContext(), ref, newStore(t, "memory"), resolver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Bundle != nil {
+		t.Errorf("expected no bundle annotations, got %v", info.Bundle)
+	}
+	if len(info.Layers) != 1 {
+		t.Fatalf("expected a single layer, got %d", len(info.Layers))
+	}
+}
+
+func TestInspectRejectsManifestList(t *testing.T) {
+	r := newRegistry(t)
+	resolver := newResolver(t, r, "", "")
+	list, pushed := pushManifestList(t, r, resolver)
+
+	r.Reset()
+	_, err := common.InspectImage(testContext(), list, newStore(t, "memory"), resolver)
+	if err == nil || !strings.Contains(err.Error(), "manifest list") {
+		t.Fatalf("expected inspecting a manifest list to fail, got %v", err)
+	}
+	if n := r.Count(http.MethodGet, "/manifests/"+pushed.String()); n != 0 {
+		t.Errorf("fetched the images of the list %d times before rejecting it", n)
+	}
+	if n := r.Count(http.MethodGet, "/blobs/"); n != 0 {
+		t.Errorf("fetched %d blobs before rejecting the list", n)
+	}
+}
+
+func descriptors(layers []common.LayerInfo) []ocispec.Descriptor {
+	var descs []ocispec.Descriptor
+	for _, l := range layers {
+		descs = append(descs, l.Descriptor)
+	}
+	return descs
+}
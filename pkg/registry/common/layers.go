@@ -0,0 +1,150 @@
+package common
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ecordell/bndlr/pkg/image/layer"
+)
+
+// LayerSpec selects files of a directory for a layer of their own, so that files that rarely change,
+// i.e. CRDs, keep the same layer digest across bundle versions
+type LayerSpec struct {
+	// Pattern is a slash-separated path or glob relative to the directory. a matching directory selects every file below it
+	Pattern string
+	// MediaType of the layer, defaults to the layer media type of the image format
+	MediaType string
+}
+
+// ParseLayerSpec parses a spec of the form path[:mediaType], i.e. `crds` or `*.clusterserviceversion.yaml:application/vnd.example.csv.layer.v1.tar+gzip`
+func ParseLayerSpec(spec string) (LayerSpec, error) {
+	pattern, mediaType := spec, ""
+	// media types always have a slash, which tells them apart from colons in the path
+	if i := strings.LastIndex(spec, ":"); i >= 0 && strings.Contains(spec[i+1:], "/") {
+		pattern, mediaType = spec[:i], spec[i+1:]
+	}
+	pattern = strings.Trim(path.Clean("/"+filepath.ToSlash(pattern)), "/")
+	if pattern == "" {
+		return LayerSpec{}, fmt.Errorf("layer %q must select a path in the directory", spec)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return LayerSpec{}, fmt.Errorf("layer %q: %v", spec, err)
+	}
+	return LayerSpec{Pattern: pattern, MediaType: mediaType}, nil
+}
+
+// matches reports whether the spec selects the file at a slash-separated path, or one of its parent directories
+func (s LayerSpec) matches(file string) bool {
+	for p := file; p != "." && p != "/"; p = path.Dir(p) {
+		if ok, _ := path.Match(s.Pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// splitDirectory assigns each file a layer built from dir with layerOptions would have to the first spec that selects it
+// it returns the files of each spec, and the files that no spec selects
+func splitDirectory(dir string, specs []LayerSpec, layerOptions []layer.LayerOption) ([]map[string]bool, map[string]bool, error) {
+	files, err := layer.ListDirectory(dir, layerOptions...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	selected := make([]map[string]bool, len(specs))
+	for i := range selected {
+		selected[i] = map[string]bool{}
+	}
+	rest := map[string]bool{}
+	for _, file := range files {
+		assigned := false
+		for i, spec := range specs {
+			if spec.matches(file) {
+				selected[i][file] = true
+				assigned = true
+				break
+			}
+		}
+		if !assigned {
+			rest[file] = true
+		}
+	}
+
+	for i, spec := range specs {
+		if len(selected[i]) == 0 {
+			return nil, nil, fmt.Errorf("layer %q selects no files in %s", spec.Pattern, dir)
+		}
+	}
+	return selected, rest, nil
+}
+
+// directoryLayers returns one layer per spec, followed by a layer of the files no spec selects and any generated files
+// without specs, the whole directory is a single layer. the files of each layer are logged at debug level
+func directoryLayers(dir string, specs []LayerSpec, layerOptions, restOptions []layer.LayerOption) (layer.Layers, error) {
+	layers, err := splitLayers(dir, specs, layerOptions, restOptions)
+	if err != nil {
+		return nil, err
+	}
+	if logrus.IsLevelEnabled(logrus.DebugLevel) {
+		for i, l := range layers {
+			files, err := l.List()
+			if err != nil {
+				return nil, err
+			}
+			for _, file := range files {
+				logrus.WithFields(logrus.Fields{"layer": i, "mediatype": l.MediaType}).Debug(file)
+			}
+		}
+	}
+	return layers, nil
+}
+
+// splitLayers builds the layers of directoryLayers
+func splitLayers(dir string, specs []LayerSpec, layerOptions, restOptions []layer.LayerOption) (layer.Layers, error) {
+	if len(specs) == 0 {
+		l, err := layer.LayerFromDirectory(dir, append(layerOptions, restOptions...)...)
+		if err != nil {
+			return nil, err
+		}
+		return layer.Layers{l}, nil
+	}
+
+	selected, rest, err := splitDirectory(dir, specs, layerOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var layers layer.Layers
+	for i, spec := range specs {
+		opts := append(append([]layer.LayerOption{}, layerOptions...), layer.WithFilter(selects(selected[i])))
+		if spec.MediaType != "" {
+			opts = append(opts, layer.WithMediaType(spec.MediaType))
+		}
+		l, err := layer.LayerFromDirectory(dir, opts...)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, l)
+	}
+
+	if len(rest) == 0 && len(restOptions) == 0 {
+		return layers, nil
+	}
+	opts := append(append([]layer.LayerOption{}, layerOptions...), layer.WithFilter(selects(rest)))
+	l, err := layer.LayerFromDirectory(dir, append(opts, restOptions...)...)
+	if err != nil {
+		return nil, err
+	}
+	return append(layers, l), nil
+}
+
+// selects returns a layer filter for a set of files
+func selects(files map[string]bool) func(string) bool {
+	return func(file string) bool {
+		return files[file]
+	}
+}
@@ -0,0 +1,212 @@
+package common_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/content"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/ecordell/bndlr/pkg/image"
+	"github.com/ecordell/bndlr/pkg/registry/common"
+	"github.com/ecordell/bndlr/pkg/registry/memory"
+	"github.com/ecordell/bndlr/pkg/registry/store"
+)
+
+func TestParseLayerSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    common.LayerSpec
+		wantErr bool
+	}{
+		{spec: "crds", want: common.LayerSpec{Pattern: "crds"}},
+		{spec: "./crds/", want: common.LayerSpec{Pattern: "crds"}},
+		{spec: "/crds", want: common.LayerSpec{Pattern: "crds"}},
+		{spec: "manifests/../crds", want: common.LayerSpec{Pattern: "crds"}},
+		{spec: "*.crd.yaml", want: common.LayerSpec{Pattern: "*.crd.yaml"}},
+		{
+			spec: "*.clusterserviceversion.yaml:application/vnd.example.csv.layer.v1.tar+gzip",
+			want: common.LayerSpec{Pattern: "*.clusterserviceversion.yaml", MediaType: "application/vnd.example.csv.layer.v1.tar+gzip"},
+		},
+		// a colon followed by something that isn't a media type is part of the path
+		{spec: "crds:v1", want: common.LayerSpec{Pattern: "crds:v1"}},
+		{spec: "crds:v1:application/vnd.example.layer", want: common.LayerSpec{Pattern: "crds:v1", MediaType: "application/vnd.example.layer"}},
+		{spec: "", wantErr: true},
+		{spec: ".", wantErr: true},
+		{spec: "/", wantErr: true},
+		{spec: ":application/vnd.example.layer", wantErr: true},
+		{spec: "crds/[", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := common.ParseLayerSpec(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %+v, got %+v", tt.spec, tt.want, got)
+		}
+	}
+}
+
+// layerFiles lists the regular files in a layer of the store
+func layerFiles(t *testing.T, s store.Store, desc ocispec.Descriptor) []string {
+	t.Helper()
+	data, err := content.ReadBlob(context.Background(), s, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r io.Reader = bytes.NewReader(data)
+	// layers are compressed whatever their media type says
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		if r, err = gzip.NewReader(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var files []string
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			files = append(files, hdr.Name)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestSplitLayers(t *testing.T) {
+	const (
+		crd     = "crds/examples.example.com.crd.yaml"
+		csv     = "example.v0.1.0.clusterserviceversion.yaml"
+		pkg     = "example.package.yaml"
+		csvType = "application/vnd.example.csv.layer.v1.tar"
+	)
+	tests := []struct {
+		name       string
+		specs      []string
+		want       [][]string
+		mediaTypes []string
+		wantErr    string
+	}{
+		{
+			name:  "directory",
+			specs: []string{"crds"},
+			want:  [][]string{{crd}, {pkg, csv}},
+		},
+		{
+			name:  "glob",
+			specs: []string{"*/*.crd.yaml"},
+			want:  [][]string{{crd}, {pkg, csv}},
+		},
+		{
+			name:       "media type",
+			specs:      []string{"*.clusterserviceversion.yaml:" + csvType},
+			want:       [][]string{{csv}, {crd, pkg}},
+			mediaTypes: []string{csvType, ""},
+		},
+		{
+			name:  "every file selected",
+			specs: []string{"crds", "*.yaml"},
+			want:  [][]string{{crd}, {pkg, csv}},
+		},
+		{
+			name:    "selects no files",
+			specs:   []string{"crds", "missing"},
+			wantErr: `layer "missing" selects no files`,
+		},
+		{
+			name:    "files already selected by an earlier spec",
+			specs:   []string{"crds", "crds/*.yaml"},
+			wantErr: `layer "crds/*.yaml" selects no files`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var specs []common.LayerSpec
+			for _, spec := range tt.specs {
+				s, err := common.ParseLayerSpec(spec)
+				if err != nil {
+					t.Fatal(err)
+				}
+				specs = append(specs, s)
+			}
+
+			s := memory.NewMemoryStore()
+			img, err := common.BuildDirectory(context.Background(), "example.com/bundle:v1", s, bundleDir, common.WithLayers(specs...))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got [][]string
+			for _, l := range img.Layers {
+				got = append(got, layerFiles(t, s, l))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected layers %v, got %v", tt.want, got)
+			}
+			for i, mediaType := range tt.mediaTypes {
+				if mediaType != "" && img.Layers[i].MediaType != mediaType {
+					t.Errorf("layer %d: expected media type %s, got %s", i, mediaType, img.Layers[i].MediaType)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitLayersKeepDigests(t *testing.T) {
+	spec, err := common.ParseLayerSpec("crds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	build := func(dir string) *image.Descriptor {
+		img, err := common.BuildDirectory(context.Background(), "example.com/bundle:v1", memory.NewMemoryStore(), dir, common.WithLayers(spec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+
+	dir := checkout(t, time.Unix(1500000000, 0))
+	before := build(dir)
+
+	// a new version of the bundle only changes the csv
+	csv := filepath.Join(dir, "example.v0.1.0.clusterserviceversion.yaml")
+	data, err := ioutil.ReadFile(csv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(csv, append(data, []byte("# v0.1.1\n")...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	after := build(dir)
+
+	if before.Layers[0].Digest != after.Layers[0].Digest {
+		t.Errorf("expected the crd layer to keep digest %s, got %s", before.Layers[0].Digest, after.Layers[0].Digest)
+	}
+	if before.Layers[1].Digest == after.Layers[1].Digest {
+		t.Errorf("expected the layer with the csv to change")
+	}
+}
@@ -0,0 +1,118 @@
+package common
+
+import (
+	"github.com/ecordell/bndlr/pkg/bundle"
+	"github.com/ecordell/bndlr/pkg/image"
+	"github.com/ecordell/bndlr/pkg/image/layer"
+	"github.com/ecordell/bndlr/pkg/image/manifest"
+)
+
+type directoryConfig struct {
+	format        manifest.Format
+	reproducible  bool
+	bundle        *bundle.Metadata
+	layers        []LayerSpec
+	layerOptions  []layer.LayerOption
+	configOptions []manifest.ConfigOption
+	// manifestOptions are applied to the generated manifest
+	manifestOptions []manifest.ManifestOption
+}
+
+// DirectoryOption configures how an image is built from a directory
+type DirectoryOption func(config *directoryConfig)
+
+func defaultDirectoryConfig() *directoryConfig {
+	return &directoryConfig{
+		format:       manifest.DockerFormat,
+		reproducible: true,
+	}
+}
+
+// apply sequentially applies the given options to the config.
+func (c *directoryConfig) apply(options []DirectoryOption) *directoryConfig {
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// WithFormat selects docker v2-2 or oci media types for the image, docker by default
+func WithFormat(format manifest.Format) DirectoryOption {
+	return func(config *directoryConfig) {
+		config.format = format
+	}
+}
+
+// WithReproducible toggles reproducible builds, which are on by default
+// reproducible images clamp file and config timestamps to SOURCE_DATE_EPOCH and normalize file ownership
+func WithReproducible(reproducible bool) DirectoryOption {
+	return func(config *directoryConfig) {
+		config.reproducible = reproducible
+	}
+}
+
+// WithLayerOptions passes options through to the layers built from the directory
+func WithLayerOptions(opts ...layer.LayerOption) DirectoryOption {
+	return func(config *directoryConfig) {
+		config.layerOptions = append(config.layerOptions, opts...)
+	}
+}
+
+// WithLayers splits the directory into a layer per spec, in order, followed by a layer of the files no spec selects
+// each file goes into the layer of the first spec that selects it
+func WithLayers(specs ...LayerSpec) DirectoryOption {
+	return func(config *directoryConfig) {
+		config.layers = append(config.layers, specs...)
+	}
+}
+
+// WithConfigOptions passes options through to the generated image config
+func WithConfigOptions(opts ...manifest.ConfigOption) DirectoryOption {
+	return func(config *directoryConfig) {
+		config.configOptions = append(config.configOptions, opts...)
+	}
+}
+
+// WithManifestOptions passes options through to the generated manifest, i.e. manifest.WithAnnotations
+func WithManifestOptions(opts ...manifest.ManifestOption) DirectoryOption {
+	return func(config *directoryConfig) {
+		config.manifestOptions = append(config.manifestOptions, opts...)
+	}
+}
+
+// WithBundleMetadata writes OLM bundle annotations into `metadata/annotations.yaml` in the layer
+// and mirrors them as config labels
+func WithBundleMetadata(metadata *bundle.Metadata) DirectoryOption {
+	return func(config *directoryConfig) {
+		config.bundle = metadata
+	}
+}
+
+// resolve returns the layer and config options to build with, including those implied by bundle metadata and reproducible builds
+// restOptions only apply to the last layer, which holds generated files
+func (c *directoryConfig) resolve() (layerOptions, restOptions []layer.LayerOption, configOptions []manifest.ConfigOption, err error) {
+	layerOptions = append([]layer.LayerOption{layer.WithMediaType(c.format.LayerMediaType())}, c.layerOptions...)
+	configOptions = c.configOptions
+	if c.bundle != nil {
+		if err := c.bundle.Validate(); err != nil {
+			return nil, nil, nil, err
+		}
+		annotationsFile, err := c.bundle.AnnotationsFile()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		restOptions = append(restOptions, layer.WithFile(bundle.AnnotationsPath(), annotationsFile))
+		configOptions = append([]manifest.ConfigOption{manifest.WithLabels(c.bundle.Annotations())}, configOptions...)
+	}
+
+	if !c.reproducible {
+		return layerOptions, restOptions, configOptions, nil
+	}
+	epoch, err := image.SourceDateEpoch()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	layerOptions = append([]layer.LayerOption{layer.WithReproducible(epoch)}, layerOptions...)
+	configOptions = append([]manifest.ConfigOption{manifest.WithCreated(epoch)}, configOptions...)
+	return layerOptions, restOptions, configOptions, nil
+}
@@ -0,0 +1,91 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/remotes"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/ecordell/bndlr/pkg/image"
+	"github.com/ecordell/bndlr/pkg/image/layer"
+	"github.com/ecordell/bndlr/pkg/registry/store"
+)
+
+// PullAndUnpackDirectory pulls an image into the store and extracts its layers, in order, into a directory
+// the uncompressed digest of each layer is checked against the diffIDs recorded in the image config before anything is extracted
+func PullAndUnpackDirectory(ctx context.Context, ref string, s store.Store, resolver remotes.Resolver, dir string) (*image.Descriptor, error) {
+	if err := rejectManifestList(ctx, resolver, ref); err != nil {
+		return nil, err
+	}
+	image, err := s.Pull(ctx, resolver, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	configReader, err := s.ReaderAt(ctx, image.Config)
+	if err != nil {
+		return nil, err
+	}
+	defer configReader.Close()
+
+	var config ocispec.Image
+	if err := json.NewDecoder(content.NewReader(configReader)).Decode(&config); err != nil {
+		return nil, err
+	}
+	diffIDs := config.RootFS.DiffIDs
+	// checked before anything is unpacked, a layer without a diffID couldn't be verified
+	if len(diffIDs) != len(image.Layers) {
+		return nil, fmt.Errorf("image %s is corrupt: config has %d diffIDs for %d layers", ref, len(diffIDs), len(image.Layers))
+	}
+
+	// every layer is verified first, so a corrupt layer doesn't leave the layers before it extracted
+	for i, l := range image.Layers {
+		if err := verifyLayer(ctx, s, l, diffIDs[i]); err != nil {
+			return nil, err
+		}
+	}
+	for i, l := range image.Layers {
+		if err := unpackLayer(ctx, s, l, dir, diffIDs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return image, nil
+}
+
+func verifyLayer(ctx context.Context, s store.Store, l ocispec.Descriptor, expected digest.Digest) error {
+	ra, err := s.ReaderAt(ctx, l)
+	if err != nil {
+		return err
+	}
+	defer ra.Close()
+
+	diffID, err := layer.DiffID(content.NewReader(ra))
+	if err != nil {
+		return fmt.Errorf("layer %s: %v", l.Digest, err)
+	}
+	if diffID != expected {
+		return fmt.Errorf("layer %s has diffID %s, expected %s", l.Digest, diffID, expected)
+	}
+	return nil
+}
+
+func unpackLayer(ctx context.Context, s store.Store, l ocispec.Descriptor, dir string, expected digest.Digest) error {
+	ra, err := s.ReaderAt(ctx, l)
+	if err != nil {
+		return err
+	}
+	defer ra.Close()
+
+	diffID, err := layer.UnpackLayer(content.NewReader(ra), dir)
+	if err != nil {
+		return err
+	}
+	if diffID != expected {
+		return fmt.Errorf("layer %s has diffID %s, expected %s", l.Digest, diffID, expected)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package common_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/containerd/containerd/content"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/ecordell/bndlr/pkg/image"
+	"github.com/ecordell/bndlr/pkg/registry/common"
+	"github.com/ecordell/bndlr/pkg/registry/memory"
+	"github.com/ecordell/bndlr/pkg/registry/store"
+)
+
+func TestPullRejectsDiffIDMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(diffIDs []digest.Digest) []digest.Digest
+	}{
+		{
+			name:   "fewer diffIDs than layers",
+			modify: func(diffIDs []digest.Digest) []digest.Digest { return diffIDs[:len(diffIDs)-1] },
+		},
+		{
+			name:   "more diffIDs than layers",
+			modify: func(diffIDs []digest.Digest) []digest.Digest { return append(diffIDs, digest.FromString("extra")) },
+		},
+		{
+			name: "wrong diffID for the second layer",
+			modify: func(diffIDs []digest.Digest) []digest.Digest {
+				return []digest.Digest{diffIDs[0], digest.FromString("corrupt")}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := testContext()
+			r := newRegistry(t)
+			resolver := newResolver(t, r, "", "")
+			s := memory.NewMemoryStore()
+
+			// two layers, so a bad second layer would leave the first one extracted if it were only checked while unpacking
+			spec, err := common.ParseLayerSpec("crds")
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, err := common.BuildDirectory(ctx, r.Ref("bundle", "built"), s, bundleDir, common.WithLayers(spec))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// rewrite the config, and the manifest pointing to it, with the modified diffIDs
+			var config map[string]interface{}
+			readJSON(t, s, img.Config, &config)
+			var diffIDs []digest.Digest
+			for _, d := range config["rootfs"].(map[string]interface{})["diff_ids"].([]interface{}) {
+				diffIDs = append(diffIDs, digest.Digest(d.(string)))
+			}
+			config["rootfs"].(map[string]interface{})["diff_ids"] = tt.modify(diffIDs)
+			corrupt := &image.Descriptor{Config: writeJSON(t, s, img.Config, config), Layers: img.Layers}
+
+			var m map[string]interface{}
+			readJSON(t, s, img.Manifest, &m)
+			m["config"] = corrupt.Config
+			corrupt.Manifest = writeJSON(t, s, img.Manifest, m)
+
+			ref := r.Ref("bundle", "corrupt")
+			if _, err := s.Push(ctx, resolver, ref, corrupt); err != nil {
+				t.Fatal(err)
+			}
+
+			dir := tempDir(t)
+			if _, err := common.PullAndUnpackDirectory(ctx, ref, memory.NewMemoryStore(), resolver, dir); err == nil {
+				t.Fatal("expected pulling an image with mismatched diffIDs to fail")
+			}
+			files, err := ioutil.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 0 {
+				t.Errorf("expected nothing to be unpacked, found %d files", len(files))
+			}
+		})
+	}
+}
+
+func TestPullRejectsManifestList(t *testing.T) {
+	r := newRegistry(t)
+	resolver := newResolver(t, r, "", "")
+	list, pushed := pushManifestList(t, r, resolver)
+
+	r.Reset()
+	dir := tempDir(t)
+	_, err := common.PullAndUnpackDirectory(testContext(), list, memory.NewMemoryStore(), resolver, dir)
+	if err == nil || !strings.Contains(err.Error(), "manifest list") {
+		t.Fatalf("expected pulling a manifest list to fail, got %v", err)
+	}
+	if n := r.Count(http.MethodGet, "/manifests/"+pushed.String()); n != 0 {
+		t.Errorf("fetched the images of the list %d times before rejecting it", n)
+	}
+	if n := r.Count(http.MethodGet, "/blobs/"); n != 0 {
+		t.Errorf("fetched %d blobs before rejecting the list", n)
+	}
+	if files, err := ioutil.ReadDir(dir); err != nil || len(files) != 0 {
+		t.Errorf("expected nothing to be unpacked, found %d files (%v)", len(files), err)
+	}
+}
+
+func readJSON(t *testing.T, s store.Store, desc ocispec.Descriptor, v interface{}) {
+	t.Helper()
+	ra, err := s.ReaderAt(context.Background(), desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ra.Close()
+	if err := json.NewDecoder(content.NewReader(ra)).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeJSON writes v to the store as a blob with the media type of like
+func writeJSON(t *testing.T, s store.Store, like ocispec.Descriptor, v interface{}) ocispec.Descriptor {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := ocispec.Descriptor{MediaType: like.MediaType, Digest: digest.FromBytes(b), Size: int64(len(b))}
+	written, err := s.Write(context.Background(), desc.Digest.String(), desc, bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return written
+}
@@ -0,0 +1,205 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/reference"
+	"github.com/containerd/containerd/remotes"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/ecordell/bndlr/pkg/image"
+	"github.com/ecordell/bndlr/pkg/registry/store"
+)
+
+// ErrNotAttempted is reported for refs that were skipped because an earlier push failed
+var ErrNotAttempted = fmt.Errorf("not attempted after an earlier failure")
+
+// PushResult is the outcome of pushing an image to a single ref
+type PushResult struct {
+	Ref    string
+	Digest digest.Digest
+	// Unchanged is set when the ref already pointed to the image, so nothing was pushed to it
+	Unchanged bool
+	Err       error
+}
+
+// PreconditionError is reported for refs that don't point to the expected manifest before a push
+type PreconditionError struct {
+	Ref string
+	// Current is the digest of the manifest the ref points to, empty if the ref doesn't exist
+	Current digest.Digest
+	// IfMatch is the digest the ref was required to point to, empty in immutable mode
+	IfMatch digest.Digest
+}
+
+func (e *PreconditionError) Error() string {
+	switch {
+	case e.IfMatch == "":
+		return fmt.Sprintf("%s already points to %s, refusing to overwrite an immutable tag", e.Ref, e.Current)
+	case e.Current == "":
+		return fmt.Sprintf("%s does not exist, expected it to point to %s", e.Ref, e.IfMatch)
+	default:
+		return fmt.Sprintf("%s points to %s, expected %s", e.Ref, e.Current, e.IfMatch)
+	}
+}
+
+type pushConfig struct {
+	bestEffort bool
+	immutable  bool
+	ifMatch    digest.Digest
+}
+
+// PushOption configures how an image is pushed to refs
+type PushOption func(config *pushConfig)
+
+func (c *pushConfig) apply(options []PushOption) *pushConfig {
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// WithBestEffort pushes to every ref even if some fail, instead of stopping at the first failure
+func WithBestEffort(bestEffort bool) PushOption {
+	return func(config *pushConfig) {
+		config.bestEffort = bestEffort
+	}
+}
+
+// WithImmutable refuses to push to refs that already point to a different manifest
+// refs that already point to the image are left as they are
+func WithImmutable(immutable bool) PushOption {
+	return func(config *pushConfig) {
+		config.immutable = immutable
+	}
+}
+
+// WithIfMatch only pushes to refs that point to the manifest with the given digest, for compare-and-swap style updates
+// registries can't tag conditionally, so a ref that changes between the check and the push is still overwritten
+func WithIfMatch(d digest.Digest) PushOption {
+	return func(config *pushConfig) {
+		config.ifMatch = d
+	}
+}
+
+// preflight checks the manifest ref points to against the immutable and if-match conditions
+// it returns whether ref already points to the image, so that pushing to it can be skipped
+func (c *pushConfig) preflight(ctx context.Context, resolver remotes.Resolver, ref string, image *image.Descriptor) (bool, error) {
+	if !c.immutable && c.ifMatch == "" {
+		return false, nil
+	}
+	current, err := currentDigest(ctx, resolver, ref)
+	if err != nil {
+		return false, err
+	}
+	if c.ifMatch != "" && current != c.ifMatch {
+		return false, &PreconditionError{Ref: ref, Current: current, IfMatch: c.ifMatch}
+	}
+	if current == image.Manifest.Digest {
+		return true, nil
+	}
+	if c.immutable && current != "" {
+		return false, &PreconditionError{Ref: ref, Current: current}
+	}
+	return false, nil
+}
+
+// currentDigest returns the digest of the manifest ref points to, or an empty digest if it doesn't exist
+func currentDigest(ctx context.Context, resolver remotes.Resolver, ref string) (digest.Digest, error) {
+	_, desc, err := resolver.Resolve(ctx, ref)
+	if errdefs.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return desc.Digest, nil
+}
+
+// PushToRefs pushes an image that has been built into the store to several refs
+// blobs are pushed once per repository, and then the manifest is tagged with each ref
+//
+// unless WithBestEffort is set, no ref is tagged until blobs have been pushed to every repository,
+// and tagging stops at the first failure. With WithBestEffort, every ref is attempted.
+// WithImmutable and WithIfMatch are checked for every ref before anything is pushed.
+// the returned error is non-nil if any ref failed
+func PushToRefs(ctx context.Context, s store.Store, resolver remotes.Resolver, image *image.Descriptor, refs []string, opts ...PushOption) ([]PushResult, error) {
+	config := (&pushConfig{}).apply(opts)
+	bestEffort := config.bestEffort
+
+	results := make([]PushResult, len(refs))
+	for i, ref := range refs {
+		results[i] = PushResult{Ref: ref, Digest: image.Manifest.Digest}
+	}
+
+	repositories := map[string][]int{}
+	var order []string
+	for i, ref := range refs {
+		spec, err := reference.Parse(ref)
+		if err == nil {
+			results[i].Unchanged, err = config.preflight(ctx, resolver, ref, image)
+		}
+		if err != nil {
+			results[i].Err = err
+			if !bestEffort {
+				notAttempted(results)
+				return results, err
+			}
+			continue
+		}
+		if results[i].Unchanged {
+			continue
+		}
+		if _, ok := repositories[spec.Locator]; !ok {
+			order = append(order, spec.Locator)
+		}
+		repositories[spec.Locator] = append(repositories[spec.Locator], i)
+	}
+
+	// push blobs once per repository
+	for _, repository := range order {
+		indexes := repositories[repository]
+		if err := store.PushBlobs(ctx, resolver, refs[indexes[0]], s, image); err != nil {
+			for _, i := range indexes {
+				results[i].Err = err
+			}
+			if !bestEffort {
+				notAttempted(results)
+				return results, fmt.Errorf("pushing blobs to %s: %v", repository, err)
+			}
+		}
+	}
+
+	// then tag each ref whose blobs made it
+	var failed error
+	for i := range results {
+		if results[i].Err != nil {
+			failed = results[i].Err
+			continue
+		}
+		if results[i].Unchanged {
+			continue
+		}
+		if err := store.PushManifest(ctx, resolver, results[i].Ref, s, image.Manifest); err != nil {
+			results[i].Err = err
+			failed = err
+			if !bestEffort {
+				notAttempted(results[i+1:])
+				return results, err
+			}
+		}
+	}
+	return results, failed
+}
+
+// notAttempted marks results without an error as skipped
+// refs that already pointed to the image are left as they are, there was nothing to attempt
+func notAttempted(results []PushResult) {
+	for i := range results {
+		if results[i].Err == nil && !results[i].Unchanged {
+			results[i].Err = ErrNotAttempted
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package common_test
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/ecordell/bndlr/pkg/image/manifest"
+	"github.com/ecordell/bndlr/pkg/registry/common"
+	"github.com/ecordell/bndlr/pkg/registry/progress"
+	registrytesting "github.com/ecordell/bndlr/pkg/registry/testing"
+)
+
+func TestPushPreflight(t *testing.T) {
+	const (
+		pushed       = "pushed"
+		unchanged    = "unchanged"
+		precondition = "precondition"
+		notAttempted = "not attempted"
+	)
+	tests := []struct {
+		name string
+		// refs are tags of the bundle repository: "same" has the image, "other" a different one, and "new" doesn't exist
+		refs    []string
+		opts    []common.PushOption
+		ifMatch bool
+		want    []string
+	}{
+		{
+			name: "immutable stops at the first conflict",
+			refs: []string{"same", "other", "new"},
+			opts: []common.PushOption{common.WithImmutable(true)},
+			want: []string{unchanged, precondition, notAttempted},
+		},
+		{
+			name: "immutable with best effort",
+			refs: []string{"same", "other", "new"},
+			opts: []common.PushOption{common.WithImmutable(true), common.WithBestEffort(true)},
+			want: []string{unchanged, precondition, pushed},
+		},
+		{
+			name: "unchanged refs after a conflict",
+			refs: []string{"other", "same"},
+			opts: []common.PushOption{common.WithImmutable(true)},
+			want: []string{precondition, notAttempted},
+		},
+		{
+			name:    "if-match",
+			refs:    []string{"same", "new", "other"},
+			ifMatch: true,
+			want:    []string{unchanged, precondition, notAttempted},
+		},
+		{
+			name:    "if-match with best effort",
+			refs:    []string{"same", "new", "other"},
+			opts:    []common.PushOption{common.WithBestEffort(true)},
+			ifMatch: true,
+			want:    []string{unchanged, precondition, precondition},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRegistry(t)
+			resolver := newResolver(t, r, "", "")
+			s := newStore(t, "memory")
+
+			img, err := common.BuildDirectory(testContext(), r.Ref("bundle", "same"), s, bundleDir, common.WithFormat(manifest.DockerFormat))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := common.PushToRefs(testContext(), s, resolver, img, []string{r.Ref("bundle", "same")}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := push(testContext(), newStore(t, "memory"), resolver, r.Ref("bundle", "other"), manifest.OCIFormat); err != nil {
+				t.Fatal(err)
+			}
+
+			var refs []string
+			for _, tag := range tt.refs {
+				refs = append(refs, r.Ref("bundle", tag))
+			}
+			opts := tt.opts
+			if tt.ifMatch {
+				opts = append(opts, common.WithIfMatch(img.Manifest.Digest))
+			}
+			results, err := common.PushToRefs(testContext(), s, resolver, img, refs, opts...)
+			if err == nil {
+				t.Error("expected the push to fail")
+			}
+
+			for i, result := range results {
+				var got string
+				switch result.Err.(type) {
+				case nil:
+					got = pushed
+					if result.Unchanged {
+						got = unchanged
+					}
+				case *common.PreconditionError:
+					got = precondition
+				default:
+					if result.Err == common.ErrNotAttempted {
+						got = notAttempted
+					} else {
+						got = result.Err.Error()
+					}
+				}
+				if got != tt.want[i] {
+					t.Errorf("%s: expected %s, got %s", tt.refs[i], tt.want[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestPushToRefsUploadsBlobsOncePerRepository(t *testing.T) {
+	r := newRegistry(t)
+	resolver := newResolver(t, r, "", "")
+	s := newStore(t, "memory")
+
+	img, err := common.BuildDirectory(testContext(), r.Ref("bundle", "v1"), s, bundleDir, common.WithFormat(manifest.DockerFormat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs := 1 + len(img.Layers)
+
+	refs := []string{r.Ref("bundle", "v1"), r.Ref("bundle", "v1.0"), r.Ref("bundle", "latest"), r.Ref("mirror", "v1")}
+	results, err := common.PushToRefs(testContext(), s, resolver, img, refs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if result.Err != nil || result.Unchanged {
+			t.Errorf("%s: expected to be pushed, got unchanged %t and error %v", result.Ref, result.Unchanged, result.Err)
+		}
+	}
+
+	if n := r.Count(http.MethodPut, "/v2/bundle/blobs/uploads/"); n != blobs {
+		t.Errorf("expected %d blobs to be uploaded to bundle for three tags, got %d", blobs, n)
+	}
+	if n := r.Count(http.MethodPut, "/v2/mirror/blobs/uploads/"); n != blobs {
+		t.Errorf("expected %d blobs to be uploaded to mirror, got %d", blobs, n)
+	}
+	if n := r.Count(http.MethodPut, "/manifests/"); n != len(refs) {
+		t.Errorf("expected a manifest put for each of %d refs, got %d", len(refs), n)
+	}
+}
+
+func TestPushToRefsBlobFailureTagsNothing(t *testing.T) {
+	for _, bestEffort := range []bool{false, true} {
+		t.Run(fmt.Sprintf("best effort %t", bestEffort), func(t *testing.T) {
+			r := newRegistry(t)
+			resolver := newResolver(t, r, "", "")
+			s := newStore(t, "memory")
+
+			img, err := common.BuildDirectory(testContext(), r.Ref("bundle", "v1"), s, bundleDir, common.WithFormat(manifest.DockerFormat))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Inject(registrytesting.Fault{Method: http.MethodPut, Path: "/blobs/uploads/", Status: http.StatusInternalServerError})
+
+			refs := []string{r.Ref("bundle", "v1"), r.Ref("bundle", "latest")}
+			results, err := common.PushToRefs(testContext(), s, resolver, img, refs, common.WithBestEffort(bestEffort))
+			if err == nil {
+				t.Fatal("expected the push to fail")
+			}
+			for _, result := range results {
+				if result.Err == nil {
+					t.Errorf("%s: expected an error", result.Ref)
+				}
+			}
+			if n := r.Count(http.MethodPut, "/blobs/uploads/"); n == 0 {
+				t.Error("expected blob uploads to be attempted")
+			}
+			if n := r.Count(http.MethodPut, "/manifests/"); n != 0 {
+				t.Errorf("put %d manifests without their blobs", n)
+			}
+		})
+	}
+}
+
+// reporter records the terminal progress event of each blob, by digest
+type reporter struct {
+	mu     sync.Mutex
+	events map[digest.Digest][]progress.EventType
+	sent   map[digest.Digest]int64
+}
+
+func (r *reporter) Report(e progress.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e.Type == progress.Progressed {
+		return
+	}
+	r.events[e.Digest] = append(r.events[e.Digest], e.Type)
+	r.sent[e.Digest] = e.Sent
+}
+
+func (r *reporter) Close() error {
+	return nil
+}
+
+func TestPushProgress(t *testing.T) {
+	reg := newRegistry(t)
+	resolver := newResolver(t, reg, "", "")
+	s := newStore(t, "memory")
+	img, err := common.BuildDirectory(testContext(), reg.Ref("bundle", "v1"), s, bundleDir, common.WithFormat(manifest.DockerFormat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs := append([]ocispec.Descriptor{img.Config}, img.Layers...)
+
+	push := func(tag string) *reporter {
+		r := &reporter{events: map[digest.Digest][]progress.EventType{}, sent: map[digest.Digest]int64{}}
+		ctx := progress.WithReporter(testContext(), r)
+		if _, err := common.PushToRefs(ctx, s, resolver, img, []string{reg.Ref("bundle", tag)}); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	r := push("v1")
+	for _, desc := range append(blobs, img.Manifest) {
+		if want := []progress.EventType{progress.Started, progress.Done}; !reflect.DeepEqual(r.events[desc.Digest], want) {
+			t.Errorf("%s: expected events %v, got %v", desc.MediaType, want, r.events[desc.Digest])
+		}
+		if r.sent[desc.Digest] != desc.Size {
+			t.Errorf("%s: expected %d bytes sent, got %d", desc.MediaType, desc.Size, r.sent[desc.Digest])
+		}
+	}
+
+	// the registry already has every blob, so none is read
+	r = push("v2")
+	for _, desc := range blobs {
+		if want := []progress.EventType{progress.Started, progress.Exists}; !reflect.DeepEqual(r.events[desc.Digest], want) {
+			t.Errorf("%s: expected events %v, got %v", desc.MediaType, want, r.events[desc.Digest])
+		}
+	}
+}
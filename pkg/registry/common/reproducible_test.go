@@ -0,0 +1,121 @@
+package common_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/content"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/ecordell/bndlr/pkg/image"
+	"github.com/ecordell/bndlr/pkg/registry/common"
+	"github.com/ecordell/bndlr/pkg/registry/memory"
+)
+
+// checkout copies the test bundle into a new directory, with every file and directory modified at mtime
+func checkout(t *testing.T, mtime time.Time) string {
+	t.Helper()
+	dir := tempDir(t)
+	err := filepath.Walk(bundleDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(bundleDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, mtime, mtime)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func buildImage(t *testing.T, dir string) (*image.Descriptor, ocispec.Image) {
+	t.Helper()
+	ctx := context.Background()
+	s := memory.NewMemoryStore()
+	img, err := common.BuildDirectory(ctx, "example.com/bundle:v1", s, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := content.ReadBlob(ctx, s, img.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config ocispec.Image
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	return img, config
+}
+
+func setEpoch(t *testing.T, value string) {
+	t.Helper()
+	previous, ok := os.LookupEnv(image.SourceDateEpochEnv)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(image.SourceDateEpochEnv, previous)
+		} else {
+			os.Unsetenv(image.SourceDateEpochEnv)
+		}
+	})
+	if err := os.Setenv(image.SourceDateEpochEnv, value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReproducibleBuild(t *testing.T) {
+	setEpoch(t, "")
+	first, firstConfig := buildImage(t, checkout(t, time.Date(2019, 10, 11, 0, 0, 0, 0, time.UTC)))
+	second, _ := buildImage(t, checkout(t, time.Now()))
+
+	if !reflect.DeepEqual(first.Layers, second.Layers) {
+		t.Errorf("expected the same layers, got %v and %v", first.Layers, second.Layers)
+	}
+	if first.Config.Digest != second.Config.Digest {
+		t.Errorf("expected the same config, got %s and %s", first.Config.Digest, second.Config.Digest)
+	}
+	if first.Manifest.Digest != second.Manifest.Digest {
+		t.Errorf("expected the same manifest, got %s and %s", first.Manifest.Digest, second.Manifest.Digest)
+	}
+	if !firstConfig.Created.Equal(time.Unix(0, 0)) {
+		t.Errorf("expected the config to be created at the unix epoch, got %s", firstConfig.Created)
+	}
+
+	setEpoch(t, "1570752000")
+	dated, datedConfig := buildImage(t, checkout(t, time.Now()))
+	if want := time.Date(2019, 10, 11, 0, 0, 0, 0, time.UTC); !datedConfig.Created.Equal(want) {
+		t.Errorf("expected the config to be created at %s, got %s", want, datedConfig.Created)
+	}
+	if dated.Config.Digest == first.Config.Digest {
+		t.Error("expected SOURCE_DATE_EPOCH to change the config digest")
+	}
+	if reflect.DeepEqual(dated.Layers, first.Layers) {
+		t.Error("expected SOURCE_DATE_EPOCH to change the layer timestamps")
+	}
+}
@@ -0,0 +1,24 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/remotes"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// rejectManifestList resolves ref and returns an error if it is a manifest list or an oci index
+// lists are rejected before anything is fetched, pulling one would fetch every image it lists
+func rejectManifestList(ctx context.Context, resolver remotes.Resolver, ref string) error {
+	_, desc, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		return err
+	}
+	switch desc.MediaType {
+	case images.MediaTypeDockerSchema2ManifestList, ocispec.MediaTypeImageIndex:
+		return fmt.Errorf("%s is a manifest list, only image manifests are supported", ref)
+	}
+	return nil
+}
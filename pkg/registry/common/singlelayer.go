@@ -0,0 +1,48 @@
+package common
+
+import (
+	"context"
+
+	"github.com/containerd/containerd/remotes"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/ecordell/bndlr/pkg/image"
+	"github.com/ecordell/bndlr/pkg/image/builder"
+	"github.com/ecordell/bndlr/pkg/registry/store"
+)
+
+// BuildAndPushDirectory builds and pushes a minimal image with single layer built from a directory, or a layer per spec set with WithLayers
+// the image is docker v2-2 unless another format is selected with WithFormat
+func BuildAndPushDirectory(ctx context.Context, ref string, s store.Store, resolver remotes.Resolver, dir string, opts ...DirectoryOption) (*digest.Digest, error) {
+	image, err := BuildDirectory(ctx, ref, s, dir, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Push(ctx, resolver, ref, image)
+}
+
+// BuildDirectory builds a minimal image with single layer built from a directory, or a layer per spec set with WithLayers, and writes it into the store
+// the image is docker v2-2 unless another format is selected with WithFormat
+func BuildDirectory(ctx context.Context, ref string, s store.Store, dir string, opts ...DirectoryOption) (*image.Descriptor, error) {
+	config := defaultDirectoryConfig().apply(opts)
+	layerOptions, restOptions, configOptions, err := config.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	builder, err := builder.NewMinimalBuilder(config.format,
+		builder.WithConfigOptions(configOptions...),
+		builder.WithManifestOptions(config.manifestOptions...),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	layers, err := directoryLayers(dir, config.layers, layerOptions, restOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return builder.BuildImage(ctx, ref, s, layers)
+}
@@ -3,33 +3,24 @@ package common
 import (
 	"context"
 
-	"github.com/containerd/containerd/images"
 	"github.com/containerd/containerd/remotes"
 	"github.com/opencontainers/go-digest"
 
-	"github.com/ecordell/bndlr/pkg/image/builder"
-	"github.com/ecordell/bndlr/pkg/image/layer"
+	"github.com/ecordell/bndlr/pkg/image"
+	"github.com/ecordell/bndlr/pkg/image/manifest"
 	"github.com/ecordell/bndlr/pkg/registry/store"
 )
 
 // This package contains aggregate functions that wire together common options exposed by underlying components
 
 // BuildAndPushDirectoryV22 builds and pushes a minimal v2-2 image with single layer built from a directory
-func BuildAndPushDirectoryV22(ctx context.Context, ref string, s store.Store, resolver remotes.Resolver, dir string) (*digest.Digest, error) {
-	builder, err := builder.NewMinimalV22Builder()
-	if err != nil {
-		return nil, err
-	}
-
-	l, err := layer.LayerFromDirectory(dir, layer.WithMediaType(images.MediaTypeDockerSchema2LayerGzip))
-	if err != nil {
-		return nil, err
-	}
-
-	image, err := builder.BuildImage(ctx, ref, s, layer.Layers{*l})
-	if err != nil {
-		return nil, err
-	}
+// images are reproducible unless disabled with WithReproducible(false)
+func BuildAndPushDirectoryV22(ctx context.Context, ref string, s store.Store, resolver remotes.Resolver, dir string, opts ...DirectoryOption) (*digest.Digest, error) {
+	return BuildAndPushDirectory(ctx, ref, s, resolver, dir, append(opts, WithFormat(manifest.DockerFormat))...)
+}
 
-	return s.Push(ctx, resolver, ref, image)
+// BuildDirectoryV22 builds a minimal v2-2 image with single layer built from a directory and writes it into the store
+// images are reproducible unless disabled with WithReproducible(false)
+func BuildDirectoryV22(ctx context.Context, ref string, s store.Store, dir string, opts ...DirectoryOption) (*image.Descriptor, error) {
+	return BuildDirectory(ctx, ref, s, dir, append(opts, WithFormat(manifest.DockerFormat))...)
 }
@@ -2,71 +2,169 @@ package filestore
 
 import (
 	"context"
+	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 
 	"github.com/containerd/containerd/content"
 	"github.com/containerd/containerd/content/local"
+	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/remotes"
 	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 
 	"github.com/ecordell/bndlr/pkg/image"
 	"github.com/ecordell/bndlr/pkg/registry/store"
 )
 
+// namesFile records the refs that name images in the store, next to the content store's `blobs` and `ingest`
+const namesFile = "names.json"
+
 type FileStore struct {
-	store    content.Store
+	store content.Store
+	names *store.Names
+
+	// tmpdir is deleted on Close, it is only set for temporary stores
+	tmpdir string
 }
 
 var _ store.Store = &FileStore{}
 
-func NewTmpFileStore() (*FileStore, error) {
+type tmpFileStoreConfig struct {
+	keep bool
+}
+
+type TmpFileStoreOption func(config *tmpFileStoreConfig)
+
+// WithKeep keeps the directory of a temporary store when it is closed, to debug what was written
+func WithKeep(keep bool) TmpFileStoreOption {
+	return func(config *tmpFileStoreConfig) {
+		config.keep = keep
+	}
+}
+
+// NewTmpFileStore returns a store in a new `bndlr-*` temporary directory, which is deleted when the store is closed
+func NewTmpFileStore(opts ...TmpFileStoreOption) (*FileStore, error) {
+	config := &tmpFileStoreConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
 	tmpdir, err := ioutil.TempDir("", "bndlr-")
 	if err != nil {
 		return nil, err
 	}
 
-	return NewFileStore(tmpdir)
+	s, err := NewFileStore(tmpdir)
+	if err != nil {
+		os.RemoveAll(tmpdir)
+		return nil, err
+	}
+	if config.keep {
+		logrus.WithField("dir", tmpdir).Info("keeping store")
+	} else {
+		s.tmpdir = tmpdir
+	}
+	return s, nil
 }
 
 func NewFileStore(dir string) (*FileStore, error) {
-	store, err := local.NewStore(dir)
+	cs, err := local.NewStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := store.LoadNames(filepath.Join(dir, namesFile))
 	if err != nil {
 		return nil, err
 	}
 	return &FileStore{
-		store: store,
+		store: cs,
+		names: names,
 	}, nil
 }
 
-func (s *FileStore) Write(ctx context.Context, ref string, descriptor ocispec.Descriptor, blob []byte) error {
-	writer, err := s.store.Writer(ctx, content.WithRef(ref))
+func (s *FileStore) Write(ctx context.Context, ref string, expected ocispec.Descriptor, r io.Reader) (ocispec.Descriptor, error) {
+	desc, err := store.WriteContent(ctx, s.store, ref, expected, r)
 	if err != nil {
-		return err
+		return ocispec.Descriptor{}, err
+	}
+	if store.IsManifest(desc.MediaType) {
+		if err := s.names.Set(ref, desc); err != nil {
+			return ocispec.Descriptor{}, err
+		}
 	}
-	_, err = writer.Write(blob)
+	return desc, nil
+}
+
+func (s *FileStore) Push(ctx context.Context, resolver remotes.Resolver, ref string, image *image.Descriptor) (*digest.Digest, error) {
+	return store.PushImage(ctx, resolver, ref, s.store, image)
+}
+
+func (s *FileStore) ReaderAt(ctx context.Context, descriptor ocispec.Descriptor) (content.ReaderAt, error) {
+	return s.store.ReaderAt(ctx, descriptor)
+}
+
+func (s *FileStore) Pull(ctx context.Context, resolver remotes.Resolver, ref string) (*image.Descriptor, error) {
+	pulled, err := store.PullImage(ctx, resolver, ref, s.store)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if err := writer.Commit(ctx, int64(len(blob)), digest.FromBytes(blob)); err != nil {
-		return err
+	if err := s.names.Set(ref, pulled.Manifest); err != nil {
+		return nil, err
 	}
-	if err := writer.Close(); err != nil {
-		return err
+	return pulled, nil
+}
+
+func (s *FileStore) Get(ctx context.Context, ref string) (*image.Descriptor, error) {
+	manifest, ok := s.names.Get(ref)
+	if !ok {
+		return nil, errors.Wrapf(errdefs.ErrNotFound, "image %s", ref)
 	}
+	return store.GetImage(ctx, s.store, manifest)
+}
 
-	return nil
+func (s *FileStore) Exists(ctx context.Context, d digest.Digest) (bool, error) {
+	return store.Exists(ctx, s.store, d)
 }
 
-func (s *FileStore) Push(ctx context.Context, resolver remotes.Resolver, ref string, image *image.Descriptor) (*digest.Digest, error) {
-	pusher, err := resolver.Pusher(ctx, ref)
+func (s *FileStore) List(ctx context.Context) ([]store.NamedImage, error) {
+	return s.names.List(), nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, d digest.Digest) error {
+	if err := store.Delete(ctx, s.store, d); err != nil {
+		return err
+	}
+	return s.names.DeleteManifest(d)
+}
+
+func (s *FileStore) Untag(ctx context.Context, ref string) error {
+	deleted, err := s.names.Delete(ref)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	if !deleted {
+		return errors.Wrapf(errdefs.ErrNotFound, "image %s", ref)
+	}
+	return nil
+}
 
-	if err := remotes.PushContent(ctx, pusher, image.Manifest, s.store, nil, nil); err != nil {
-		return nil, err
+// GC deletes the blobs that aren't reachable from a named image, and any unfinished writes
+func (s *FileStore) GC(ctx context.Context) ([]ocispec.Descriptor, error) {
+	var roots []ocispec.Descriptor
+	for _, named := range s.names.List() {
+		roots = append(roots, named.Manifest)
 	}
-	return &image.Manifest.Digest, nil
+	return store.GC(ctx, s.store, roots)
 }
 
+// Close deletes the directory of a temporary store, other stores are left as they are
+func (s *FileStore) Close() error {
+	if s.tmpdir == "" {
+		return nil
+	}
+	return os.RemoveAll(s.tmpdir)
+}
@@ -0,0 +1,69 @@
+package filestore_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/ecordell/bndlr/pkg/registry/filestore"
+	"github.com/ecordell/bndlr/pkg/registry/store"
+	"github.com/ecordell/bndlr/pkg/registry/store/storetest"
+)
+
+func TestFileStore(t *testing.T) {
+	storetest.Test(t, func(t *testing.T) store.Store {
+		dir, err := ioutil.TempDir("", "bndlr-test-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.RemoveAll(dir) })
+
+		s, err := filestore.NewFileStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+func TestTmpFileStoreClose(t *testing.T) {
+	if previous, ok := os.LookupEnv("TMPDIR"); ok {
+		defer os.Setenv("TMPDIR", previous)
+	} else {
+		defer os.Unsetenv("TMPDIR")
+	}
+
+	for _, keep := range []bool{false, true} {
+		tmp, err := ioutil.TempDir("", "bndlr-test-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.RemoveAll(tmp) })
+		if err := os.Setenv("TMPDIR", tmp); err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := filestore.NewTmpFileStore(filestore.WithKeep(keep))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Write(context.Background(), "test", ocispec.Descriptor{}, strings.NewReader("content")); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := ioutil.ReadDir(tmp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kept := len(entries) == 1; kept != keep {
+			t.Errorf("keep %t: found %d store directories after closing", keep, len(entries))
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package registry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/ghodss/yaml"
+
+	"github.com/ecordell/bndlr/pkg/registry/retry"
+)
+
+// HostConfig holds the connection settings for a registry host
+type HostConfig struct {
+	// PlainHTTP talks to the registry over http instead of https
+	PlainHTTP bool `json:"plainHTTP,omitempty"`
+	// InsecureSkipTLSVerify accepts any certificate presented by the registry
+	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
+	// CAFile is a PEM bundle of certificate authorities trusted in addition to the system roots
+	CAFile string `json:"caFile,omitempty"`
+	// CertFile and KeyFile are a PEM client certificate and key presented to the registry
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+}
+
+// HostsConfig maps registry hosts, i.e. `quay.io` or `registry.internal:5000`, to their settings
+type HostsConfig map[string]HostConfig
+
+// LoadHostsConfig reads a yaml or json file of per-host settings, i.e.
+//
+//	registry.internal:5000:
+//	  plainHTTP: true
+//	quay.internal:
+//	  caFile: /etc/pki/internal-ca.pem
+func LoadHostsConfig(path string) (HostsConfig, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := HostsConfig{}
+	if err := yaml.Unmarshal(raw, &config); err != nil {
+		return nil, fmt.Errorf("error parsing hosts config %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// merge layers host specific settings over the defaults
+// booleans can only be enabled for a host, files replace the defaults when set
+func (c HostConfig) merge(host HostConfig) HostConfig {
+	c.PlainHTTP = c.PlainHTTP || host.PlainHTTP
+	c.InsecureSkipTLSVerify = c.InsecureSkipTLSVerify || host.InsecureSkipTLSVerify
+	if host.CAFile != "" {
+		c.CAFile = host.CAFile
+	}
+	if host.CertFile != "" || host.KeyFile != "" {
+		c.CertFile = host.CertFile
+		c.KeyFile = host.KeyFile
+	}
+	return c
+}
+
+// tlsConfig returns the tls config for the settings, or nil if the defaults apply
+func (c HostConfig) tlsConfig() (*tls.Config, error) {
+	if !c.InsecureSkipTLSVerify && c.CAFile == "" && c.CertFile == "" && c.KeyFile == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipTLSVerify,
+	}
+	if c.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return nil, fmt.Errorf("client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// client returns an http client for the settings, which retries requests with the policy on their context
+func (c HostConfig) client() (*http.Client, error) {
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	var transport http.RoundTripper = http.DefaultTransport
+	if tlsConfig != nil {
+		// same as http.DefaultTransport, with the tls config set
+		transport = &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+			TLSClientConfig:       tlsConfig,
+		}
+	}
+	return &http.Client{Transport: retry.NewTransport(transport)}, nil
+}
+
+// registryHosts configures each registry host on first use and reuses it after,
+// so that clients and the tokens held by authorizers are shared between requests
+type registryHosts struct {
+	defaults    HostConfig
+	hosts       HostsConfig
+	credentials func(string) (string, string, error)
+
+	mu         sync.Mutex
+	configured map[string][]docker.RegistryHost
+}
+
+func (r *registryHosts) lookup(host string) ([]docker.RegistryHost, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if hosts, ok := r.configured[host]; ok {
+		return hosts, nil
+	}
+
+	config := r.defaults.merge(r.hosts[host])
+	client, err := config.client()
+	if err != nil {
+		return nil, fmt.Errorf("error configuring registry %s: %v", host, err)
+	}
+
+	plainHTTP := docker.MatchLocalhost
+	if config.PlainHTTP {
+		plainHTTP = docker.MatchAllHosts
+	}
+	hosts, err := docker.ConfigureDefaultRegistries(
+		docker.WithClient(client),
+		docker.WithPlainHTTP(plainHTTP),
+		docker.WithAuthorizer(docker.NewDockerAuthorizer(
+			docker.WithAuthClient(client),
+			docker.WithAuthCreds(r.credentials),
+		)),
+	)(host)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.configured == nil {
+		r.configured = map[string][]docker.RegistryHost{}
+	}
+	r.configured[host] = hosts
+	return hosts, nil
+}
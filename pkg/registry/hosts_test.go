@@ -0,0 +1,162 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHostConfigMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults HostConfig
+		host     HostConfig
+		want     HostConfig
+	}{
+		{
+			name:     "no host settings",
+			defaults: HostConfig{PlainHTTP: true, CAFile: "ca.pem"},
+			want:     HostConfig{PlainHTTP: true, CAFile: "ca.pem"},
+		},
+		{
+			name: "host enables booleans",
+			host: HostConfig{PlainHTTP: true, InsecureSkipTLSVerify: true},
+			want: HostConfig{PlainHTTP: true, InsecureSkipTLSVerify: true},
+		},
+		{
+			name:     "host can't disable booleans",
+			defaults: HostConfig{PlainHTTP: true, InsecureSkipTLSVerify: true},
+			host:     HostConfig{},
+			want:     HostConfig{PlainHTTP: true, InsecureSkipTLSVerify: true},
+		},
+		{
+			name:     "host replaces the ca file",
+			defaults: HostConfig{CAFile: "default.pem"},
+			host:     HostConfig{CAFile: "host.pem"},
+			want:     HostConfig{CAFile: "host.pem"},
+		},
+		{
+			name:     "host replaces the client certificate and key together",
+			defaults: HostConfig{CertFile: "default.crt", KeyFile: "default.key"},
+			host:     HostConfig{CertFile: "host.crt"},
+			want:     HostConfig{CertFile: "host.crt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.defaults.merge(tt.host); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadHostsConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bndlr-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "hosts.yaml")
+	if err := ioutil.WriteFile(path, []byte("registry.internal:5000:\n  plainHTTP: true\nquay.internal:\n  caFile: /etc/pki/internal-ca.pem\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := LoadHostsConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := HostsConfig{
+		"registry.internal:5000": {PlainHTTP: true},
+		"quay.internal":          {CAFile: "/etc/pki/internal-ca.pem"},
+	}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("expected %+v, got %+v", want, hosts)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("- not a map\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadHostsConfig(path); err == nil {
+		t.Error("expected an invalid hosts config to fail")
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bndlr-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	empty := filepath.Join(dir, "empty.pem")
+	if err := ioutil.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  HostConfig
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "defaults", wantNil: true},
+		{name: "plain http", config: HostConfig{PlainHTTP: true}, wantNil: true},
+		{name: "insecure", config: HostConfig{InsecureSkipTLSVerify: true}},
+		{name: "ca file without certificates", config: HostConfig{CAFile: empty}, wantErr: true},
+		{name: "missing ca file", config: HostConfig{CAFile: filepath.Join(dir, "missing.pem")}, wantErr: true},
+		{name: "certificate without key", config: HostConfig{CertFile: "client.crt"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tt.config.tlsConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (config == nil) != tt.wantNil {
+				t.Errorf("expected nil config %t, got %+v", tt.wantNil, config)
+			}
+		})
+	}
+}
+
+func TestRegistryHostsLookup(t *testing.T) {
+	hosts := newRegistryHosts(nil, WithHostsConfig(HostsConfig{
+		"registry.internal:5000": {PlainHTTP: true},
+	}))
+
+	tests := []struct {
+		host   string
+		scheme string
+	}{
+		{host: "registry.internal:5000", scheme: "http"},
+		{host: "quay.io", scheme: "https"},
+		{host: "localhost:5000", scheme: "http"},
+	}
+	for _, tt := range tests {
+		configured, err := hosts.lookup(tt.host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(configured) == 0 || configured[0].Scheme != tt.scheme {
+			t.Errorf("%s: expected scheme %s, got %+v", tt.host, tt.scheme, configured)
+		}
+		again, err := hosts.lookup(tt.host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again[0].Client != configured[0].Client {
+			t.Errorf("%s: expected the client to be reused between lookups", tt.host)
+		}
+	}
+
+	defaults := newRegistryHosts(nil, WithPlainHTTP(true))
+	configured, err := defaults.lookup("quay.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configured[0].Scheme != "http" {
+		t.Errorf("expected --plain-http to apply to every host, got scheme %s", configured[0].Scheme)
+	}
+}
@@ -0,0 +1,169 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrUnauthorized is returned by Login when a registry rejects the credentials
+var ErrUnauthorized = fmt.Errorf("unauthorized: incorrect username or password")
+
+// Login checks that a registry host accepts a username and password
+// registries that use token auth are asked for a token with the credentials, as `docker login` does
+func Login(ctx context.Context, host, username, password string, opts ...ResolverOption) error {
+	hosts, err := newRegistryHosts(nil, opts...).lookup(host)
+	if err != nil {
+		return err
+	}
+	registryHost := hosts[0]
+	base := (&url.URL{Scheme: registryHost.Scheme, Host: registryHost.Host, Path: registryHost.Path + "/"}).String()
+
+	resp, err := do(ctx, registryHost.Client, base, "", "")
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// the registry doesn't require auth, there is nothing to check the credentials against
+		return nil
+	case http.StatusUnauthorized:
+	default:
+		return fmt.Errorf("unexpected response from %s: %s", base, resp.Status)
+	}
+
+	scheme, params := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	switch scheme {
+	case "basic":
+		resp, err = do(ctx, registryHost.Client, base, username, password)
+	case "bearer":
+		return fetchToken(ctx, registryHost.Client, params, username, password)
+	default:
+		return fmt.Errorf("unsupported auth scheme %q from %s", scheme, base)
+	}
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	default:
+		return fmt.Errorf("unexpected response from %s: %s", base, resp.Status)
+	}
+}
+
+// fetchToken requests a token from the realm of a bearer challenge with basic auth
+func fetchToken(ctx context.Context, client *http.Client, params map[string]string, username, password string) error {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return fmt.Errorf("invalid token auth realm %q", params["realm"])
+	}
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	if scope := params["scope"]; scope != "" {
+		query.Set("scope", scope)
+	}
+	query.Set("account", username)
+	realm.RawQuery = query.Encode()
+
+	resp, err := do(ctx, client, realm.String(), username, password)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	default:
+		return fmt.Errorf("unexpected response from %s: %s", realm.Host, resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(resp.body, &token); err != nil {
+		return fmt.Errorf("invalid token response from %s: %v", realm.Host, err)
+	}
+	if token.Token == "" && token.AccessToken == "" {
+		return fmt.Errorf("no token in response from %s", realm.Host)
+	}
+	return nil
+}
+
+type response struct {
+	*http.Response
+	body []byte
+}
+
+// do sends a GET with basic auth if username or password are set, and reads the response
+func do(ctx context.Context, client *http.Client, u, username, password string) (*response, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if username != "" || password != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	return &response{Response: resp, body: body}, nil
+}
+
+// parseChallenge parses a WWW-Authenticate header into its lowercased scheme and parameters, i.e.
+// `Bearer realm="https://auth.docker.io/token",service="registry.docker.io"`
+func parseChallenge(header string) (string, map[string]string) {
+	params := map[string]string{}
+	header = strings.TrimSpace(header)
+	i := strings.IndexByte(header, ' ')
+	if i < 0 {
+		return strings.ToLower(header), params
+	}
+	scheme, rest := strings.ToLower(header[:i]), header[i+1:]
+
+	for {
+		rest = strings.TrimLeft(rest, " ,")
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return scheme, params
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			// quoted values may contain commas, i.e. scopes
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value = strings.TrimSpace(rest[:end])
+			rest = rest[end:]
+		}
+		params[key] = value
+	}
+}
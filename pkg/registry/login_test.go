@@ -0,0 +1,115 @@
+package registry_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/containerd/errdefs"
+
+	"github.com/ecordell/bndlr/pkg/registry"
+	"github.com/ecordell/bndlr/pkg/registry/auth"
+	registrytesting "github.com/ecordell/bndlr/pkg/registry/testing"
+)
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []registrytesting.RegistryOption
+		username string
+		password string
+		wantErr  error
+	}{
+		{
+			name:     "no auth",
+			username: "alice",
+			password: "anything",
+		},
+		{
+			name:     "basic",
+			opts:     []registrytesting.RegistryOption{registrytesting.WithBasicAuth("alice", "s3cret")},
+			username: "alice",
+			password: "s3cret",
+		},
+		{
+			name:     "basic with wrong password",
+			opts:     []registrytesting.RegistryOption{registrytesting.WithBasicAuth("alice", "s3cret")},
+			username: "alice",
+			password: "wrong",
+			wantErr:  registry.ErrUnauthorized,
+		},
+		{
+			name:     "token",
+			opts:     []registrytesting.RegistryOption{registrytesting.WithTokenAuth("alice", "s3cret")},
+			username: "alice",
+			password: "s3cret",
+		},
+		{
+			name:     "token with wrong username",
+			opts:     []registrytesting.RegistryOption{registrytesting.WithTokenAuth("alice", "s3cret")},
+			username: "bob",
+			password: "s3cret",
+			wantErr:  registry.ErrUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := registrytesting.NewRegistry(tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			err = registry.Login(context.Background(), r.Host(), tt.username, tt.password)
+			if err != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestLoginStoresCredentials logs in the way the login command does, and checks the stored credentials are used
+func TestLoginStoresCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bndlr-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	authFile := filepath.Join(dir, "auth.json")
+
+	r, err := registrytesting.NewRegistry(registrytesting.WithTokenAuth("alice", "s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	ctx := context.Background()
+	ref := r.Ref("bundle", "missing")
+
+	resolve := func() error {
+		resolver, err := registry.NewResolver("", "", []string{authFile})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = resolver.Resolve(ctx, ref)
+		return err
+	}
+	if err := resolve(); err == nil || errdefs.IsNotFound(err) {
+		t.Fatalf("expected resolving without credentials to be unauthorized, got %v", err)
+	}
+
+	if err := registry.Login(ctx, r.Host(), "alice", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	creds, err := auth.NewCredentials(authFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := creds.Store(r.Host(), "alice", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := resolve(); !errdefs.IsNotFound(err) {
+		t.Fatalf("expected resolving with stored credentials to find nothing, got %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// contentStore is a content.Store that keeps blobs in memory by digest
+// writes are buffered until they are committed, there are no ingests to resume
+type contentStore struct {
+	mu    sync.Mutex
+	blobs map[digest.Digest]*blob
+}
+
+type blob struct {
+	data      []byte
+	createdAt time.Time
+}
+
+var _ content.Store = &contentStore{}
+
+func newContentStore() *contentStore {
+	return &contentStore{blobs: map[digest.Digest]*blob{}}
+}
+
+func (s *contentStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs = map[digest.Digest]*blob{}
+}
+
+func (s *contentStore) get(d digest.Digest) (*blob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.blobs[d]
+	if !ok {
+		return nil, errors.Wrapf(errdefs.ErrNotFound, "content %v", d)
+	}
+	return b, nil
+}
+
+func (s *contentStore) Info(ctx context.Context, d digest.Digest) (content.Info, error) {
+	b, err := s.get(d)
+	if err != nil {
+		return content.Info{}, err
+	}
+	return content.Info{Digest: d, Size: int64(len(b.data)), CreatedAt: b.createdAt, UpdatedAt: b.createdAt}, nil
+}
+
+func (s *contentStore) Update(ctx context.Context, info content.Info, fieldpaths ...string) (content.Info, error) {
+	return content.Info{}, errors.Wrap(errdefs.ErrNotImplemented, "labels aren't kept in memory")
+}
+
+// Walk calls fn with every blob, filters aren't supported
+func (s *contentStore) Walk(ctx context.Context, fn content.WalkFunc, filters ...string) error {
+	s.mu.Lock()
+	infos := make([]content.Info, 0, len(s.blobs))
+	for d, b := range s.blobs {
+		infos = append(infos, content.Info{Digest: d, Size: int64(len(b.data)), CreatedAt: b.createdAt, UpdatedAt: b.createdAt})
+	}
+	s.mu.Unlock()
+
+	for _, info := range infos {
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *contentStore) Delete(ctx context.Context, d digest.Digest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.blobs[d]; !ok {
+		return errors.Wrapf(errdefs.ErrNotFound, "content %v", d)
+	}
+	delete(s.blobs, d)
+	return nil
+}
+
+func (s *contentStore) ReaderAt(ctx context.Context, desc ocispec.Descriptor) (content.ReaderAt, error) {
+	b, err := s.get(desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	return readerAt{bytes.NewReader(b.data)}, nil
+}
+
+func (s *contentStore) Status(ctx context.Context, ref string) (content.Status, error) {
+	return content.Status{}, errors.Wrapf(errdefs.ErrNotFound, "status for ref %v", ref)
+}
+
+func (s *contentStore) ListStatuses(ctx context.Context, filters ...string) ([]content.Status, error) {
+	return nil, nil
+}
+
+func (s *contentStore) Abort(ctx context.Context, ref string) error {
+	return errors.Wrapf(errdefs.ErrNotFound, "ingest ref %v", ref)
+}
+
+// Writer returns a writer that stores its content by the digest computed as it is written
+// like other content stores, writing content that is already stored fails with errdefs.ErrAlreadyExists
+func (s *contentStore) Writer(ctx context.Context, opts ...content.WriterOpt) (content.Writer, error) {
+	var wOpts content.WriterOpts
+	for _, opt := range opts {
+		if err := opt(&wOpts); err != nil {
+			return nil, err
+		}
+	}
+	if wOpts.Ref == "" {
+		return nil, errors.Wrap(errdefs.ErrInvalidArgument, "ref must not be empty")
+	}
+	if d := wOpts.Desc.Digest; d != "" {
+		if _, err := s.get(d); err == nil {
+			return nil, errors.Wrapf(errdefs.ErrAlreadyExists, "content %v", d)
+		}
+	}
+	now := time.Now()
+	return &writer{
+		store:    s,
+		digester: digest.Canonical.Digester(),
+		status: content.Status{
+			Ref:       wOpts.Ref,
+			Total:     wOpts.Desc.Size,
+			Expected:  wOpts.Desc.Digest,
+			StartedAt: now,
+			UpdatedAt: now,
+		},
+	}, nil
+}
+
+type writer struct {
+	store    *contentStore
+	buffer   bytes.Buffer
+	digester digest.Digester
+	status   content.Status
+	closed   bool
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.buffer.Write(p)
+	w.digester.Hash().Write(p[:n])
+	w.status.Offset += int64(n)
+	w.status.UpdatedAt = time.Now()
+	return n, err
+}
+
+func (w *writer) Close() error {
+	w.closed = true
+	return nil
+}
+
+func (w *writer) Digest() digest.Digest {
+	return w.digester.Digest()
+}
+
+func (w *writer) Status() (content.Status, error) {
+	return w.status, nil
+}
+
+func (w *writer) Truncate(size int64) error {
+	if size != 0 {
+		return errors.New("Truncate: unsupported size")
+	}
+	w.buffer.Reset()
+	w.digester.Hash().Reset()
+	w.status.Offset = 0
+	return nil
+}
+
+func (w *writer) Commit(ctx context.Context, size int64, expected digest.Digest, opts ...content.Opt) error {
+	if w.closed {
+		return errors.Wrap(errdefs.ErrFailedPrecondition, "cannot commit on closed writer")
+	}
+	w.closed = true
+
+	if size > 0 && size != int64(w.buffer.Len()) {
+		return errors.Wrapf(errdefs.ErrFailedPrecondition, "unexpected commit size %d, expected %d", w.buffer.Len(), size)
+	}
+	d := w.digester.Digest()
+	if expected != "" && expected != d {
+		return errors.Wrapf(errdefs.ErrFailedPrecondition, "unexpected commit digest %s, expected %s", d, expected)
+	}
+
+	w.store.mu.Lock()
+	defer w.store.mu.Unlock()
+	if _, ok := w.store.blobs[d]; ok {
+		return errors.Wrapf(errdefs.ErrAlreadyExists, "content %v", d)
+	}
+	w.store.blobs[d] = &blob{data: append([]byte(nil), w.buffer.Bytes()...), createdAt: time.Now()}
+	return nil
+}
+
+// readerAt reads a blob in memory, there is nothing to close
+type readerAt struct {
+	*bytes.Reader
+}
+
+func (r readerAt) Close() error {
+	return nil
+}
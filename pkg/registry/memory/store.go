@@ -2,38 +2,110 @@ package memory
 
 import (
 	"context"
+	"io"
 
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/remotes"
-	orascontent "github.com/deislabs/oras/pkg/content"
-	"github.com/deislabs/oras/pkg/oras"
 	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
 
 	"github.com/ecordell/bndlr/pkg/image"
 	"github.com/ecordell/bndlr/pkg/registry/store"
 )
 
 type MemoryStore struct {
-	store *orascontent.Memorystore
+	store *contentStore
+	names *store.Names
 }
 
 var _ store.Store = &MemoryStore{}
 
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		store: orascontent.NewMemoryStore(),
+		store: newContentStore(),
+		names: store.NewNames(),
 	}
 }
 
-func (s *MemoryStore) Write(ctx context.Context, ref string, descriptor ocispec.Descriptor, blob []byte) error {
-	s.store.Set(descriptor, blob)
-	return nil
+// Write buffers the whole blob in memory, this is the only store that doesn't stream content to disk
+func (s *MemoryStore) Write(ctx context.Context, ref string, expected ocispec.Descriptor, r io.Reader) (ocispec.Descriptor, error) {
+	desc, err := store.WriteContent(ctx, s.store, ref, expected, r)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if store.IsManifest(desc.MediaType) {
+		if err := s.names.Set(ref, desc); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+	return desc, nil
 }
 
 func (s *MemoryStore) Push(ctx context.Context, resolver remotes.Resolver, ref string, image *image.Descriptor) (*digest.Digest, error) {
-	desc, err := oras.Push(ctx, resolver, ref, s.store, image.Layers, oras.WithConfig(image.Config), oras.WithManifest(image.Manifest), oras.WithNameValidation(nil))
+	return store.PushImage(ctx, resolver, ref, s.store, image)
+}
+
+func (s *MemoryStore) ReaderAt(ctx context.Context, descriptor ocispec.Descriptor) (content.ReaderAt, error) {
+	return s.store.ReaderAt(ctx, descriptor)
+}
+
+func (s *MemoryStore) Pull(ctx context.Context, resolver remotes.Resolver, ref string) (*image.Descriptor, error) {
+	pulled, err := store.PullImage(ctx, resolver, ref, s.store)
 	if err != nil {
 		return nil, err
 	}
-	return &desc.Digest, nil
+	if err := s.names.Set(ref, pulled.Manifest); err != nil {
+		return nil, err
+	}
+	return pulled, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, ref string) (*image.Descriptor, error) {
+	manifest, ok := s.names.Get(ref)
+	if !ok {
+		return nil, errors.Wrapf(errdefs.ErrNotFound, "image %s", ref)
+	}
+	return store.GetImage(ctx, s.store, manifest)
+}
+
+func (s *MemoryStore) Exists(ctx context.Context, d digest.Digest) (bool, error) {
+	return store.Exists(ctx, s.store, d)
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]store.NamedImage, error) {
+	return s.names.List(), nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, d digest.Digest) error {
+	if err := store.Delete(ctx, s.store, d); err != nil {
+		return err
+	}
+	return s.names.DeleteManifest(d)
+}
+
+func (s *MemoryStore) Untag(ctx context.Context, ref string) error {
+	deleted, err := s.names.Delete(ref)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.Wrapf(errdefs.ErrNotFound, "image %s", ref)
+	}
+	return nil
+}
+
+func (s *MemoryStore) GC(ctx context.Context) ([]ocispec.Descriptor, error) {
+	var roots []ocispec.Descriptor
+	for _, named := range s.names.List() {
+		roots = append(roots, named.Manifest)
+	}
+	return store.GC(ctx, s.store, roots)
+}
+
+// Close drops the blobs held in memory
+func (s *MemoryStore) Close() error {
+	s.store.reset()
+	return nil
 }
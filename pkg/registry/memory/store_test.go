@@ -0,0 +1,15 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/ecordell/bndlr/pkg/registry/memory"
+	"github.com/ecordell/bndlr/pkg/registry/store"
+	"github.com/ecordell/bndlr/pkg/registry/store/storetest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Test(t, func(t *testing.T) store.Store {
+		return memory.NewMemoryStore()
+	})
+}
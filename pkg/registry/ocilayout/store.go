@@ -0,0 +1,183 @@
+package ocilayout
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/remotes"
+	orascontent "github.com/deislabs/oras/pkg/content"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+
+	"github.com/ecordell/bndlr/pkg/image"
+	"github.com/ecordell/bndlr/pkg/registry/store"
+)
+
+// OCILayoutStore writes images into a directory following the OCI image layout spec
+// (`oci-layout`, `index.json`, `blobs/sha256/...`), so that it can be consumed by other tools
+type OCILayoutStore struct {
+	store *orascontent.OCIStore
+	root  string
+}
+
+var _ store.Store = &OCILayoutStore{}
+
+func NewOCILayoutStore(dir string) (*OCILayoutStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	store, err := orascontent.NewOCIStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &OCILayoutStore{
+		store: store,
+		root:  dir,
+	}, nil
+}
+
+// Write streams a blob into the layout
+// manifests are also added to `index.json`, named by ref
+func (s *OCILayoutStore) Write(ctx context.Context, ref string, expected ocispec.Descriptor, r io.Reader) (ocispec.Descriptor, error) {
+	if err := s.createIngest(); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	desc, err := store.WriteContent(ctx, s.store, ref, expected, r)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	if store.IsManifest(desc.MediaType) {
+		if err := s.tag(ref, desc); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+	return desc, nil
+}
+
+func (s *OCILayoutStore) ReaderAt(ctx context.Context, descriptor ocispec.Descriptor) (content.ReaderAt, error) {
+	return s.store.ReaderAt(ctx, descriptor)
+}
+
+func (s *OCILayoutStore) Push(ctx context.Context, resolver remotes.Resolver, ref string, image *image.Descriptor) (*digest.Digest, error) {
+	return store.PushImage(ctx, resolver, ref, s.store, image)
+}
+
+func (s *OCILayoutStore) Pull(ctx context.Context, resolver remotes.Resolver, ref string) (*image.Descriptor, error) {
+	if err := s.createIngest(); err != nil {
+		return nil, err
+	}
+	pulled, err := store.PullImage(ctx, resolver, ref, s.store)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tag(ref, pulled.Manifest); err != nil {
+		return nil, err
+	}
+	return pulled, nil
+}
+
+// Get returns the image named ref in `index.json`
+func (s *OCILayoutStore) Get(ctx context.Context, ref string) (*image.Descriptor, error) {
+	manifest, ok := s.store.ListReferences()[ref]
+	if !ok {
+		return nil, errors.Wrapf(errdefs.ErrNotFound, "image %s", ref)
+	}
+	return store.GetImage(ctx, s.store, manifest)
+}
+
+func (s *OCILayoutStore) Exists(ctx context.Context, d digest.Digest) (bool, error) {
+	return store.Exists(ctx, s.store, d)
+}
+
+// List returns the images named in `index.json`
+func (s *OCILayoutStore) List(ctx context.Context) ([]store.NamedImage, error) {
+	var named []store.NamedImage
+	for ref, desc := range s.store.ListReferences() {
+		named = append(named, store.NamedImage{Ref: ref, Manifest: desc})
+	}
+	sort.Slice(named, func(i, j int) bool { return named[i].Ref < named[j].Ref })
+	return named, nil
+}
+
+// Delete removes a blob, and its entries in `index.json` if it is a manifest
+func (s *OCILayoutStore) Delete(ctx context.Context, d digest.Digest) error {
+	if err := store.Delete(ctx, s.store, d); err != nil {
+		return err
+	}
+	for ref, desc := range s.store.ListReferences() {
+		if desc.Digest == d {
+			s.store.DeleteReference(ref)
+		}
+	}
+	return s.store.SaveIndex()
+}
+
+// Untag removes the entry for ref from `index.json`
+func (s *OCILayoutStore) Untag(ctx context.Context, ref string) error {
+	if _, ok := s.store.ListReferences()[ref]; !ok {
+		return errors.Wrapf(errdefs.ErrNotFound, "image %s", ref)
+	}
+	s.store.DeleteReference(ref)
+	return s.store.SaveIndex()
+}
+
+// GC deletes the blobs that aren't reachable from an image in `index.json`
+func (s *OCILayoutStore) GC(ctx context.Context) ([]ocispec.Descriptor, error) {
+	if err := s.createIngest(); err != nil {
+		return nil, err
+	}
+	var roots []ocispec.Descriptor
+	for _, desc := range s.store.ListReferences() {
+		roots = append(roots, desc)
+	}
+	deleted, err := store.GC(ctx, s.store, roots)
+	if err != nil {
+		return deleted, err
+	}
+	return deleted, s.removeIngest()
+}
+
+// tag records a manifest under a name in `index.json`
+func (s *OCILayoutStore) tag(ref string, desc ocispec.Descriptor) error {
+	s.store.AddReference(ref, desc)
+	if err := s.store.SaveIndex(); err != nil {
+		return err
+	}
+	return s.removeIngest()
+}
+
+// createIngest recreates `ingest/` before writes, the content store expects it to exist since it was opened
+func (s *OCILayoutStore) createIngest() error {
+	return os.MkdirAll(filepath.Join(s.root, "ingest"), 0755)
+}
+
+// removeIngest removes `ingest/`, where the content store stages writes, because it is not part of the layout
+// it is only removed once empty, so in-progress writes are left alone
+func (s *OCILayoutStore) removeIngest() error {
+	ingest := filepath.Join(s.root, "ingest")
+	entries, err := ioutil.ReadDir(ingest)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(entries) == 0 {
+		return os.Remove(ingest)
+	}
+	return nil
+}
+
+// Close leaves the layout in place, it is the output of a build
+// only the ingest directory is removed, if no writes were left unfinished
+func (s *OCILayoutStore) Close() error {
+	return s.removeIngest()
+}
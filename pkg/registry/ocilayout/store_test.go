@@ -0,0 +1,27 @@
+package ocilayout_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ecordell/bndlr/pkg/registry/ocilayout"
+	"github.com/ecordell/bndlr/pkg/registry/store"
+	"github.com/ecordell/bndlr/pkg/registry/store/storetest"
+)
+
+func TestOCILayoutStore(t *testing.T) {
+	storetest.Test(t, func(t *testing.T) store.Store {
+		dir, err := ioutil.TempDir("", "bndlr-test-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { os.RemoveAll(dir) })
+
+		s, err := ocilayout.NewOCILayoutStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
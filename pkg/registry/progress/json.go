@@ -0,0 +1,35 @@
+package progress
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// JSONReporter writes each event as a line of json
+type JSONReporter struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	totals Totals
+}
+
+var _ Reporter = &JSONReporter{}
+
+func NewJSONReporter(w io.Writer) *JSONReporter {
+	return &JSONReporter{enc: json.NewEncoder(w)}
+}
+
+func (r *JSONReporter) Report(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.totals.add(e)
+	r.enc.Encode(e)
+}
+
+func (r *JSONReporter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	totals := r.totals
+	return r.enc.Encode(Event{Type: Summary, Time: time.Now(), Totals: &totals})
+}
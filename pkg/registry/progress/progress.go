@@ -0,0 +1,210 @@
+package progress
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/images"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// EventType is the kind of a progress event
+type EventType string
+
+const (
+	// Started is reported when a blob push begins
+	Started EventType = "start"
+	// Progressed is reported periodically while a blob is sent
+	Progressed EventType = "progress"
+	// Exists is reported when a blob is skipped because the registry already has it
+	Exists EventType = "exists"
+	// Done is reported when a blob has been pushed
+	Done EventType = "done"
+	// Failed is reported when a blob can't be pushed, after retries
+	Failed EventType = "error"
+	// Result is reported for each ref once all pushes are finished, with the manifest digest and the error if the ref failed
+	Result EventType = "result"
+	// Summary is reported once all pushes are finished, with totals
+	Summary EventType = "summary"
+)
+
+// interval is the minimum time between Progressed events for a blob
+const interval = 200 * time.Millisecond
+
+// Event describes progress of a blob push, the outcome for a ref, or totals for a Summary
+type Event struct {
+	Type      EventType     `json:"type"`
+	Time      time.Time     `json:"time"`
+	Ref       string        `json:"ref,omitempty"`
+	Digest    digest.Digest `json:"digest,omitempty"`
+	MediaType string        `json:"mediaType,omitempty"`
+	Size      int64         `json:"size,omitempty"`
+	// Sent is the number of bytes of the blob sent so far, it restarts at 0 when a push is retried
+	Sent int64 `json:"sent,omitempty"`
+	// Unchanged is set on a Result when the ref already pointed to the image, so nothing was pushed to it
+	Unchanged bool    `json:"unchanged,omitempty"`
+	Error     string  `json:"error,omitempty"`
+	Totals    *Totals `json:"totals,omitempty"`
+}
+
+// Totals sums up all blob pushes
+type Totals struct {
+	Pushed int   `json:"pushed"`
+	Exists int   `json:"exists"`
+	Failed int   `json:"failed"`
+	Sent   int64 `json:"sent"`
+}
+
+func (t *Totals) add(e Event) {
+	switch e.Type {
+	case Done:
+		t.Pushed++
+		t.Sent += e.Sent
+	case Exists:
+		t.Exists++
+	case Failed:
+		t.Failed++
+	}
+}
+
+// A Reporter receives progress events. Reports may come from concurrent pushes
+type Reporter interface {
+	Report(e Event)
+	// Close reports a Summary and flushes any output
+	Close() error
+}
+
+type reporterKey struct{}
+
+// WithReporter sets the reporter for pushes using the context
+func WithReporter(ctx context.Context, r Reporter) context.Context {
+	return context.WithValue(ctx, reporterKey{}, r)
+}
+
+// ReporterFrom returns the reporter set on the context, or nil
+func ReporterFrom(ctx context.Context) Reporter {
+	r, _ := ctx.Value(reporterKey{}).(Reporter)
+	return r
+}
+
+type blobKey struct{}
+
+// blob tracks the push of a single blob, it is set on the context by Handler and updated by Provider
+type blob struct {
+	reporter Reporter
+	event    Event
+
+	mu       sync.Mutex
+	opened   bool
+	sent     int64
+	reported time.Time
+}
+
+func (b *blob) open() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.opened = true
+	b.sent = 0
+}
+
+func (b *blob) add(n int) {
+	b.mu.Lock()
+	b.sent += int64(n)
+	if time.Since(b.reported) < interval {
+		b.mu.Unlock()
+		return
+	}
+	b.reported = time.Now()
+	e := b.with(Progressed)
+	b.mu.Unlock()
+
+	b.reporter.Report(e)
+}
+
+// with returns the blob's event of the given type, callers must hold the lock
+func (b *blob) with(t EventType) Event {
+	e := b.event
+	e.Type = t
+	e.Time = time.Now()
+	e.Sent = b.sent
+	return e
+}
+
+// Handler reports the progress of pushes by h, i.e. remotes.PushHandler, for ref to the reporter on the context
+// h must read content through a Provider for sent bytes to be counted.
+// blobs whose content is never read are reported as already existing
+func Handler(ref string, h images.Handler) images.HandlerFunc {
+	return func(ctx context.Context, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+		r := ReporterFrom(ctx)
+		if r == nil {
+			return h.Handle(ctx, desc)
+		}
+
+		b := &blob{
+			reporter: r,
+			event: Event{
+				Ref:       ref,
+				Digest:    desc.Digest,
+				MediaType: desc.MediaType,
+				Size:      desc.Size,
+			},
+		}
+		b.mu.Lock()
+		r.Report(b.with(Started))
+		b.mu.Unlock()
+
+		children, err := h.Handle(context.WithValue(ctx, blobKey{}, b), desc)
+
+		b.mu.Lock()
+		var e Event
+		switch {
+		case err != nil:
+			e = b.with(Failed)
+			e.Error = err.Error()
+		case !b.opened:
+			e = b.with(Exists)
+		default:
+			e = b.with(Done)
+		}
+		b.mu.Unlock()
+		r.Report(e)
+
+		return children, err
+	}
+}
+
+// Provider counts bytes read from p towards the progress of pushes by Handler
+func Provider(p content.Provider) content.Provider {
+	return &provider{Provider: p}
+}
+
+type provider struct {
+	content.Provider
+}
+
+func (p *provider) ReaderAt(ctx context.Context, desc ocispec.Descriptor) (content.ReaderAt, error) {
+	ra, err := p.Provider.ReaderAt(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := ctx.Value(blobKey{}).(*blob)
+	if !ok {
+		return ra, nil
+	}
+	b.open()
+	return &readerAt{ReaderAt: ra, blob: b}, nil
+}
+
+type readerAt struct {
+	content.ReaderAt
+	blob *blob
+}
+
+func (r *readerAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.ReaderAt.ReadAt(p, off)
+	r.blob.add(n)
+	return n, err
+}
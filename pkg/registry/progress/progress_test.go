@@ -0,0 +1,227 @@
+package progress_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/images"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/ecordell/bndlr/pkg/registry/progress"
+)
+
+// recorder keeps every reported event
+type recorder struct {
+	mu     sync.Mutex
+	events []progress.Event
+}
+
+func (r *recorder) Report(e progress.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recorder) Close() error {
+	return nil
+}
+
+// types returns the type of each event, leaving out Progressed events since they depend on timing
+func (r *recorder) types() []progress.EventType {
+	var types []progress.EventType
+	for _, e := range r.events {
+		if e.Type != progress.Progressed {
+			types = append(types, e.Type)
+		}
+	}
+	return types
+}
+
+// provider serves a single blob from memory
+type provider []byte
+
+func (p provider) ReaderAt(ctx context.Context, desc ocispec.Descriptor) (content.ReaderAt, error) {
+	return readerAt{bytes.NewReader(p)}, nil
+}
+
+type readerAt struct {
+	*bytes.Reader
+}
+
+func (readerAt) Close() error {
+	return nil
+}
+
+func TestHandler(t *testing.T) {
+	blob := []byte("layer content")
+	desc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageLayer, Digest: digest.FromBytes(blob), Size: int64(len(blob))}
+	failed := errors.New("upload failed")
+
+	// read pushes the blob by reading it through the provider, as remotes.PushHandler does
+	read := func(ctx context.Context, p content.Provider) error {
+		ra, err := p.ReaderAt(ctx, desc)
+		if err != nil {
+			return err
+		}
+		defer ra.Close()
+		_, err = ioutil.ReadAll(content.NewReader(ra))
+		return err
+	}
+
+	tests := []struct {
+		name     string
+		push     func(ctx context.Context, p content.Provider) error
+		want     []progress.EventType
+		wantSent int64
+		wantErr  string
+	}{
+		{
+			name:     "pushed",
+			push:     read,
+			want:     []progress.EventType{progress.Started, progress.Done},
+			wantSent: desc.Size,
+		},
+		{
+			name: "already exists",
+			push: func(ctx context.Context, p content.Provider) error { return nil },
+			want: []progress.EventType{progress.Started, progress.Exists},
+		},
+		{
+			name: "retried",
+			push: func(ctx context.Context, p content.Provider) error {
+				if err := read(ctx, p); err != nil {
+					return err
+				}
+				return read(ctx, p)
+			},
+			want: []progress.EventType{progress.Started, progress.Done},
+			// sent restarts with each attempt
+			wantSent: desc.Size,
+		},
+		{
+			name:    "failed",
+			push:    func(ctx context.Context, p content.Provider) error { return failed },
+			want:    []progress.EventType{progress.Started, progress.Failed},
+			wantErr: failed.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{}
+			p := progress.Provider(provider(blob))
+			h := progress.Handler("example.com/bundle:v1", images.HandlerFunc(func(ctx context.Context, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+				return nil, tt.push(ctx, p)
+			}))
+
+			if _, err := h(progress.WithReporter(context.Background(), r), desc); (err != nil) != (tt.wantErr != "") {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got := r.types(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected events %v, got %v", tt.want, got)
+			}
+			for _, e := range r.events {
+				if e.Ref != "example.com/bundle:v1" || e.Digest != desc.Digest || e.MediaType != desc.MediaType || e.Size != desc.Size {
+					t.Errorf("expected %s events to describe the blob, got %+v", e.Type, e)
+				}
+				if e.Sent > desc.Size {
+					t.Errorf("expected no more than %d bytes sent, got %d", desc.Size, e.Sent)
+				}
+			}
+			last := r.events[len(r.events)-1]
+			if last.Sent != tt.wantSent {
+				t.Errorf("expected %d bytes sent, got %d", tt.wantSent, last.Sent)
+			}
+			if last.Error != tt.wantErr {
+				t.Errorf("expected error %q, got %q", tt.wantErr, last.Error)
+			}
+		})
+	}
+}
+
+func TestHandlerWithoutReporter(t *testing.T) {
+	called := false
+	h := progress.Handler("example.com/bundle:v1", images.HandlerFunc(func(ctx context.Context, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+		called = true
+		// content is read as usual, there is just nothing to report it to
+		ra, err := progress.Provider(provider("content")).ReaderAt(ctx, desc)
+		if err != nil {
+			return nil, err
+		}
+		return nil, ra.Close()
+	}))
+	if _, err := h(context.Background(), ocispec.Descriptor{}); err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Error("expected the wrapped handler to be called")
+	}
+}
+
+func TestJSONReporter(t *testing.T) {
+	var out bytes.Buffer
+	r := progress.NewJSONReporter(&out)
+	d := digest.FromString("layer")
+	events := []progress.Event{
+		{Type: progress.Started, Ref: "example.com/bundle:v1", Digest: d, MediaType: ocispec.MediaTypeImageLayer, Size: 10},
+		{Type: progress.Done, Ref: "example.com/bundle:v1", Digest: d, MediaType: ocispec.MediaTypeImageLayer, Size: 10, Sent: 10},
+		{Type: progress.Exists, Ref: "example.com/bundle:v1", Digest: digest.FromString("config"), Size: 5},
+		{Type: progress.Failed, Ref: "example.com/other:v1", Digest: d, Size: 10, Error: "upload failed"},
+		{Type: progress.Result, Ref: "example.com/bundle:v1", Digest: digest.FromString("manifest"), Unchanged: true},
+	}
+	for _, e := range events {
+		r.Report(e)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		typ  progress.EventType
+		keys []string
+	}{
+		{typ: progress.Started, keys: []string{"digest", "mediaType", "ref", "size", "time", "type"}},
+		{typ: progress.Done, keys: []string{"digest", "mediaType", "ref", "sent", "size", "time", "type"}},
+		{typ: progress.Exists, keys: []string{"digest", "ref", "size", "time", "type"}},
+		{typ: progress.Failed, keys: []string{"digest", "error", "ref", "size", "time", "type"}},
+		{typ: progress.Result, keys: []string{"digest", "ref", "time", "type", "unchanged"}},
+		{typ: progress.Summary, keys: []string{"time", "totals", "type"}},
+	}
+	scanner := bufio.NewScanner(&out)
+	var lines []map[string]interface{}
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("expected a json event per line, got %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d events, got %d", len(want), len(lines))
+	}
+	for i, w := range want {
+		var keys []string
+		for k := range lines[i] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		if lines[i]["type"] != string(w.typ) || !reflect.DeepEqual(keys, w.keys) {
+			t.Errorf("event %d: expected a %s event with %v, got %v", i, w.typ, w.keys, lines[i])
+		}
+	}
+
+	totals := lines[len(lines)-1]["totals"]
+	wantTotals := map[string]interface{}{"pushed": 1.0, "exists": 1.0, "failed": 1.0, "sent": 10.0}
+	if !reflect.DeepEqual(totals, wantTotals) {
+		t.Errorf("expected totals %v, got %v", wantTotals, totals)
+	}
+}
@@ -0,0 +1,121 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+const barWidth = 30
+
+// TerminalReporter draws a live progress bar for each blob, redrawing them in place as events arrive
+type TerminalReporter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	order  []string
+	blobs  map[string]Event
+	drawn  int
+	totals Totals
+}
+
+var _ Reporter = &TerminalReporter{}
+
+func NewTerminalReporter(w io.Writer) *TerminalReporter {
+	return &TerminalReporter{w: w, blobs: map[string]Event{}}
+}
+
+// IsTerminal reports whether f is a character device, i.e. an interactive terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (r *TerminalReporter) Report(e Event) {
+	// results are printed once the bars are done
+	if e.Type == Result {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.totals.add(e)
+	key := e.Ref + "@" + e.Digest.String()
+	if _, ok := r.blobs[key]; !ok {
+		r.order = append(r.order, key)
+	}
+	r.blobs[key] = e
+	r.draw()
+}
+
+func (r *TerminalReporter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.draw()
+	_, err := fmt.Fprintf(r.w, "%d pushed, %d already existed, %d failed, %s sent\n",
+		r.totals.Pushed, r.totals.Exists, r.totals.Failed, humanBytes(r.totals.Sent))
+	return err
+}
+
+// draw moves the cursor back over the previous drawing and redraws every blob
+func (r *TerminalReporter) draw() {
+	var b strings.Builder
+	if r.drawn > 0 {
+		fmt.Fprintf(&b, "\033[%dA", r.drawn)
+	}
+	for _, key := range r.order {
+		b.WriteString("\033[2K")
+		b.WriteString(line(r.blobs[key]))
+		b.WriteString("\n")
+	}
+	r.drawn = len(r.order)
+	io.WriteString(r.w, b.String())
+}
+
+// line renders a blob as `<ref> <short digest> [=====>    ] 1.2 MiB / 3.4 MiB`
+func line(e Event) string {
+	name := fmt.Sprintf("%s %s", e.Ref, shortDigest(e))
+	switch e.Type {
+	case Exists:
+		return fmt.Sprintf("%s already exists", name)
+	case Failed:
+		return fmt.Sprintf("%s failed: %s", name, e.Error)
+	case Done:
+		return fmt.Sprintf("%s pushed %s", name, humanBytes(e.Sent))
+	}
+
+	filled := 0
+	if e.Size > 0 {
+		filled = int(e.Sent * barWidth / e.Size)
+	}
+	if filled > barWidth {
+		filled = barWidth
+	}
+	bar := strings.Repeat("=", filled)
+	if filled < barWidth {
+		bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+	return fmt.Sprintf("%s [%s] %s / %s", name, bar, humanBytes(e.Sent), humanBytes(e.Size))
+}
+
+func shortDigest(e Event) string {
+	hex := e.Digest.Hex()
+	if len(hex) > 12 {
+		hex = hex[:12]
+	}
+	return hex
+}
+
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -1,32 +1,89 @@
 package registry
 
 import (
-	"context"
-	"fmt"
-	"os"
-
 	"github.com/containerd/containerd/remotes"
 	"github.com/containerd/containerd/remotes/docker"
-	auth "github.com/deislabs/oras/pkg/auth/docker"
+
+	"github.com/ecordell/bndlr/pkg/registry/auth"
 )
 
-func NewResolver(username, password string, configs ...string) remotes.Resolver {
-	if username != "" || password != "" {
-		return docker.NewResolver(docker.ResolverOptions{
-			Credentials: func(hostName string) (string, string, error) {
-				return username, password, nil
-			},
-		})
+type resolverConfig struct {
+	defaults HostConfig
+	hosts    HostsConfig
+}
+
+// A ResolverOption configures how the resolver connects to registries
+type ResolverOption func(config *resolverConfig)
+
+// WithPlainHTTP talks to every registry over http. localhost registries always use http
+func WithPlainHTTP(plainHTTP bool) ResolverOption {
+	return func(config *resolverConfig) {
+		config.defaults.PlainHTTP = plainHTTP
+	}
+}
+
+// WithInsecureSkipTLSVerify accepts any certificate presented by a registry
+func WithInsecureSkipTLSVerify(insecure bool) ResolverOption {
+	return func(config *resolverConfig) {
+		config.defaults.InsecureSkipTLSVerify = insecure
+	}
+}
+
+// WithCAFile trusts the certificate authorities in a PEM bundle in addition to the system roots
+func WithCAFile(path string) ResolverOption {
+	return func(config *resolverConfig) {
+		config.defaults.CAFile = path
 	}
-	cli, err := auth.NewClient(configs...)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "WARNING: Error loading auth file: %v\n", err)
+}
+
+// WithClientCertificate presents a PEM client certificate and key to registries
+func WithClientCertificate(certFile, keyFile string) ResolverOption {
+	return func(config *resolverConfig) {
+		config.defaults.CertFile = certFile
+		config.defaults.KeyFile = keyFile
+	}
+}
+
+// WithHostsConfig applies per-host settings on top of the other options, see LoadHostsConfig
+func WithHostsConfig(hosts HostsConfig) ResolverOption {
+	return func(config *resolverConfig) {
+		config.hosts = hosts
 	}
-	resolver, err := cli.Resolver(context.Background())
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "WARNING: Error loading resolver: %v\n", err)
-		resolver = docker.NewResolver(docker.ResolverOptions{})
+}
+
+// NewResolver returns a resolver that authenticates with username and password if either is set,
+// and otherwise with credentials from the auth files in configs, or auth.DefaultFiles() if there are none
+// an *auth.Error is returned if an auth file can't be loaded
+func NewResolver(username, password string, configs []string, opts ...ResolverOption) (remotes.Resolver, error) {
+	var credentials func(string) (string, string, error)
+	if username != "" || password != "" {
+		credentials = func(hostName string) (string, string, error) {
+			return username, password, nil
+		}
+	} else {
+		if len(configs) == 0 {
+			configs = auth.DefaultFiles()
+		}
+		creds, err := auth.NewCredentials(configs...)
+		if err != nil {
+			return nil, err
+		}
+		credentials = creds.Credential
 	}
-	return resolver
+
+	return docker.NewResolver(docker.ResolverOptions{
+		Hosts: newRegistryHosts(credentials, opts...).lookup,
+	}), nil
 }
 
+func newRegistryHosts(credentials func(string) (string, string, error), opts ...ResolverOption) *registryHosts {
+	config := &resolverConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return &registryHosts{
+		defaults:    config.defaults,
+		hosts:       config.hosts,
+		credentials: credentials,
+	}
+}
@@ -0,0 +1,78 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/remotes"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type namespaceKey struct{}
+
+// WithStatusNamespace namespaces the resolver's push status for content pushed by Handler
+// the resolver tracks pushes by digest alone, so without a namespace, content pushed to one
+// repository is reported as already existing in every other repository
+func WithStatusNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, namespace)
+}
+
+// Handler retries pushes by h, i.e. remotes.PushHandler, that fail with a retryable error, using the policy on the context
+// each attempt is tracked under its own status key, since the resolver would otherwise
+// report content streamed during a failed attempt as already pushed
+func Handler(h images.Handler) images.HandlerFunc {
+	return func(ctx context.Context, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+		namespace, _ := ctx.Value(namespaceKey{}).(string)
+		operation := fmt.Sprintf("push of %s", desc.Digest)
+
+		var children []ocispec.Descriptor
+		err := PolicyFrom(ctx).Do(ctx, operation, func(attempt int) error {
+			key := fmt.Sprintf("%s@%s#%d", namespace, desc.MediaType, attempt)
+			// WithMediaTypeKeyPrefix updates a prefix map already on the context in place, which would race
+			// between concurrent handlers, so the map must never be set on a parent context
+			attemptCtx := remotes.WithMediaTypeKeyPrefix(ctx, desc.MediaType, key)
+			attemptCtx, failures := withFailures(attemptCtx)
+
+			var err error
+			children, err = h.Handle(attemptCtx, desc)
+			if err != nil && AsError(err) == nil {
+				// pushers don't always keep the cause of a failed upload, i.e. `no response`
+				if failure := failures.last(); failure != nil {
+					err = &Error{StatusCode: failure.StatusCode, RetryAfter: failure.RetryAfter, Err: err}
+				}
+			}
+			return err
+		})
+		return children, err
+	}
+}
+
+type failuresKey struct{}
+
+// failures records retryable failures of requests that the transport couldn't retry itself
+type failures struct {
+	mu      sync.Mutex
+	failure *Error
+}
+
+func withFailures(ctx context.Context) (context.Context, *failures) {
+	f := &failures{}
+	return context.WithValue(ctx, failuresKey{}, f), f
+}
+
+// recordFailure notes a retryable failure on the context, if it is recording failures
+func recordFailure(ctx context.Context, err *Error) {
+	if f, ok := ctx.Value(failuresKey{}).(*failures); ok {
+		f.mu.Lock()
+		f.failure = err
+		f.mu.Unlock()
+	}
+}
+
+func (f *failures) last() *Error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.failure
+}
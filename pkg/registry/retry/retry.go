@@ -0,0 +1,152 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Policy controls how registry operations are retried
+type Policy struct {
+	// Attempts is the total number of tries, including the first. 1 disables retries
+	Attempts int
+	// InitialBackoff is the wait before the first retry, it doubles with each attempt
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between attempts, unless a registry asks for longer with Retry-After
+	MaxBackoff time.Duration
+	// Jitter randomizes each wait by up to this fraction of it, i.e. 0.2 for ±20%
+	Jitter float64
+}
+
+// DefaultPolicy is used when no policy is set on the context
+var DefaultPolicy = Policy{
+	Attempts:       5,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+	Jitter:         0.2,
+}
+
+type policyKey struct{}
+
+// WithPolicy sets the retry policy for registry operations using the context
+func WithPolicy(ctx context.Context, p Policy) context.Context {
+	return context.WithValue(ctx, policyKey{}, p)
+}
+
+// PolicyFrom returns the retry policy set on the context, or DefaultPolicy
+func PolicyFrom(ctx context.Context) Policy {
+	if p, ok := ctx.Value(policyKey{}).(Policy); ok {
+		return p
+	}
+	return DefaultPolicy
+}
+
+// An Error is a failure that may succeed if the operation is tried again
+type Error struct {
+	// StatusCode is the http status returned by the registry, 0 for network errors
+	StatusCode int
+	// RetryAfter is the wait requested by the registry, if any
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("unexpected status: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// AsError returns the retryable *Error wrapped by err, or nil if it isn't retryable
+// both pkg/errors causes and standard library wrapping, i.e. url.Error, are followed
+func AsError(err error) *Error {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e
+		}
+		switch wrapped := err.(type) {
+		case interface{ Cause() error }:
+			err = wrapped.Cause()
+		case interface{ Unwrap() error }:
+			err = wrapped.Unwrap()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// Retryable reports whether an http status may succeed if the request is tried again
+// that is request timeouts, rate limiting, and server errors other than unsupported features
+func Retryable(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	case http.StatusNotImplemented, http.StatusHTTPVersionNotSupported:
+		return false
+	}
+	return statusCode >= 500
+}
+
+// Do calls fn until it succeeds, returns an error that isn't retryable, or attempts run out
+// fn is passed the number of the attempt, starting at 0
+func (p Policy) Do(ctx context.Context, operation string, fn func(attempt int) error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn(attempt)
+		if err == nil {
+			return nil
+		}
+		retryable := AsError(err)
+		if retryable == nil || attempt+1 >= p.Attempts {
+			return err
+		}
+		if waitErr := p.wait(ctx, operation, attempt, retryable.RetryAfter, err); waitErr != nil {
+			return err
+		}
+	}
+}
+
+// wait logs a retry and sleeps for the backoff of the attempt, returning early if the context is done
+func (p Policy) wait(ctx context.Context, operation string, attempt int, retryAfter time.Duration, cause error) error {
+	wait := p.backoff(attempt)
+	if retryAfter > wait {
+		wait = retryAfter
+	}
+	logrus.WithFields(logrus.Fields{
+		"attempt": attempt + 2,
+		"of":      p.Attempts,
+		"wait":    wait,
+	}).Warnf("retrying %s: %v", operation, cause)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff returns the wait before retrying after the attempt, with jitter applied
+func (p Policy) backoff(attempt int) time.Duration {
+	wait := p.InitialBackoff
+	for i := 0; i < attempt && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		wait += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(wait))
+	}
+	return wait
+}
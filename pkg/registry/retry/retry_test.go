@@ -0,0 +1,242 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{status: http.StatusRequestTimeout, want: true},
+		{status: http.StatusTooManyRequests, want: true},
+		{status: http.StatusInternalServerError, want: true},
+		{status: http.StatusBadGateway, want: true},
+		{status: http.StatusServiceUnavailable, want: true},
+		{status: http.StatusGatewayTimeout, want: true},
+		{status: http.StatusNotImplemented, want: false},
+		{status: http.StatusHTTPVersionNotSupported, want: false},
+		{status: http.StatusOK, want: false},
+		{status: http.StatusBadRequest, want: false},
+		{status: http.StatusUnauthorized, want: false},
+		{status: http.StatusNotFound, want: false},
+	}
+	for _, tt := range tests {
+		if got := Retryable(tt.status); got != tt.want {
+			t.Errorf("Retryable(%d) = %t, want %t", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		min    time.Duration
+		max    time.Duration
+	}{
+		{name: "missing"},
+		{name: "seconds", header: "3", min: 3 * time.Second, max: 3 * time.Second},
+		{name: "zero seconds", header: "0"},
+		{name: "negative seconds", header: "-5"},
+		{name: "http date", header: time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), min: 58 * time.Second, max: time.Minute},
+		{name: "past http date", header: time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)},
+		{name: "invalid", header: "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.header != "" {
+				resp.Header.Set("Retry-After", tt.header)
+			}
+			if got := retryAfter(resp); got < tt.min || got > tt.max {
+				t.Errorf("expected a wait between %s and %s, got %s", tt.min, tt.max, got)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := Policy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	want := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for attempt, w := range want {
+		if got := p.backoff(attempt); got != w {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, w, got)
+		}
+	}
+	// doubling stops at the cap, so late attempts don't overflow
+	if got := p.backoff(100); got != time.Second {
+		t.Errorf("attempt 100: expected %s, got %s", time.Second, got)
+	}
+
+	p.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(5); got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			t.Fatalf("expected a jittered wait within 20%% of %s, got %s", time.Second, got)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	p := Policy{Attempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	failed := errors.New("failed")
+
+	tests := []struct {
+		name     string
+		errs     []error
+		wantErr  error
+		attempts int
+	}{
+		{name: "success", errs: []error{nil}, attempts: 1},
+		{name: "retried until success", errs: []error{&Error{StatusCode: http.StatusServiceUnavailable}, nil}, attempts: 2},
+		{name: "wrapped retryable error", errs: []error{fmt.Errorf("push: %w", &Error{StatusCode: http.StatusTooManyRequests}), nil}, attempts: 2},
+		{name: "not retryable", errs: []error{failed}, wantErr: failed, attempts: 1},
+		{name: "attempts run out", errs: []error{&Error{StatusCode: 500}, &Error{StatusCode: 502}, failed, nil}, wantErr: failed, attempts: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := p.Do(context.Background(), "test", func(attempt int) error {
+				if attempt != attempts {
+					t.Errorf("expected attempt %d, got %d", attempts, attempt)
+				}
+				attempts++
+				return tt.errs[attempt]
+			})
+			if err != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("expected %d attempts, got %d", tt.attempts, attempts)
+			}
+		})
+	}
+}
+
+func TestDoWaitsForRetryAfter(t *testing.T) {
+	p := Policy{Attempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	start := time.Now()
+	err := p.Do(context.Background(), "test", func(attempt int) error {
+		if attempt == 0 {
+			return &Error{StatusCode: http.StatusTooManyRequests, RetryAfter: 50 * time.Millisecond}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Retry-After is honoured even though it's longer than MaxBackoff
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected to wait for Retry-After, retried after %s", elapsed)
+	}
+}
+
+func TestDoStopsWhenContextIsDone(t *testing.T) {
+	p := Policy{Attempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	err := p.Do(ctx, "test", func(int) error {
+		attempts++
+		return &Error{StatusCode: http.StatusServiceUnavailable}
+	})
+	if AsError(err) == nil || attempts != 1 {
+		t.Errorf("expected the first failure after 1 attempt, got %v after %d", err, attempts)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		body       bool
+		wantStatus int
+		attempts   int32
+	}{
+		{name: "success", statuses: []int{200}, wantStatus: 200, attempts: 1},
+		{name: "rate limited", statuses: []int{429, 200}, wantStatus: 200, attempts: 2},
+		{name: "server errors", statuses: []int{500, 503, 200}, wantStatus: 200, attempts: 3},
+		{name: "replayable body", statuses: []int{502, 201}, body: true, wantStatus: 201, attempts: 2},
+		{name: "not implemented", statuses: []int{501, 200}, wantStatus: 501, attempts: 1},
+		{name: "client error", statuses: []int{404, 200}, wantStatus: 404, attempts: 1},
+		{name: "attempts run out", statuses: []int{503, 503, 503, 200}, wantStatus: 503, attempts: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := atomic.AddInt32(&attempts, 1) - 1
+				if tt.body {
+					// every attempt must send the whole body
+					if b, err := ioutil.ReadAll(r.Body); err != nil || string(b) != "content" {
+						t.Errorf("attempt %d: expected the body to be replayed, got %q (%v)", attempt, b, err)
+					}
+				}
+				w.WriteHeader(tt.statuses[attempt])
+			}))
+			defer server.Close()
+
+			ctx := WithPolicy(context.Background(), Policy{Attempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+			method, body := http.MethodGet, ""
+			if tt.body {
+				method, body = http.MethodPut, "content"
+			}
+			req, err := http.NewRequestWithContext(ctx, method, server.URL, strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := (&http.Client{Transport: NewTransport(nil)}).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if got := atomic.LoadInt32(&attempts); got != tt.attempts {
+				t.Errorf("expected %d attempts, got %d", tt.attempts, got)
+			}
+		})
+	}
+}
+
+func TestTransportRecordsUnreplayableFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, failures := withFailures(WithPolicy(context.Background(), Policy{Attempts: 3}))
+	// a streamed upload can't be sent again, so it's left to the caller to retry
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, server.URL, ioutil.NopCloser(strings.NewReader("content")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewTransport(nil).RoundTrip(req)
+	retryErr := AsError(err)
+	if retryErr == nil || retryErr.StatusCode != http.StatusTooManyRequests || retryErr.RetryAfter != 7*time.Second {
+		t.Fatalf("expected a retryable 429 waiting 7s, got %#v", err)
+	}
+	if failures.last() != retryErr {
+		t.Errorf("expected the failure to be recorded for Handler, got %v", failures.last())
+	}
+}
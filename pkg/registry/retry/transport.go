@@ -0,0 +1,108 @@
+package retry
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Transport retries requests that fail with network errors or retryable statuses,
+// using the policy set on each request's context
+// requests with bodies that can't be replayed, i.e. streamed uploads, are not retried here.
+// their failures are returned as an *Error, and recorded for Handler to retry the whole upload
+type Transport struct {
+	Base http.RoundTripper
+}
+
+var _ http.RoundTripper = &Transport{}
+
+// NewTransport wraps base, or http.DefaultTransport if base is nil, with retries
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	p := PolicyFrom(ctx)
+	operation := fmt.Sprintf("%s %s", req.Method, req.URL.String())
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.Base.RoundTrip(req)
+		var retryErr *Error
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			retryErr = &Error{Err: err}
+		case Retryable(resp.StatusCode):
+			retryErr = &Error{StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp)}
+		default:
+			return resp, nil
+		}
+		if attempt+1 >= p.Attempts {
+			return resp, err
+		}
+
+		body, bodyErr := replayBody(req)
+		if resp != nil {
+			drain(resp)
+		}
+		if bodyErr != nil {
+			// the caller has to retry with a fresh body
+			recordFailure(ctx, retryErr)
+			return nil, retryErr
+		}
+		if err := p.wait(ctx, operation, attempt, retryErr.RetryAfter, retryErr); err != nil {
+			return nil, err
+		}
+		req = cloneRequest(req, body)
+	}
+}
+
+// replayBody returns a fresh body for sending a request again
+func replayBody(req *http.Request) (io.ReadCloser, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req.Body, nil
+	}
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("request body can't be replayed")
+	}
+	// GetBody may still fail, i.e. for bodies that can only be read once
+	return req.GetBody()
+}
+
+func cloneRequest(req *http.Request, body io.ReadCloser) *http.Request {
+	clone := req.WithContext(req.Context())
+	clone.Body = body
+	return clone
+}
+
+// drain reads and closes a response body so its connection can be reused
+func drain(resp *http.Response) {
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16))
+	resp.Body.Close()
+}
+
+// retryAfter parses the Retry-After header, which is either seconds or an http date
+func retryAfter(resp *http.Response) time.Duration {
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
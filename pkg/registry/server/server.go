@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"os"
+
+	"github.com/docker/distribution/configuration"
+	dcontext "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/handlers"
+	_ "github.com/docker/distribution/registry/storage/driver/filesystem"
+	"github.com/sirupsen/logrus"
+)
+
+// Server serves the distribution v2 api with distribution's own handlers, over a filesystem storage driver
+type Server struct {
+	app    *handlers.App
+	logger *logrus.Entry
+}
+
+type serverConfig struct {
+	logger logrus.FieldLogger
+}
+
+// ServerOption configures a Server
+type ServerOption func(config *serverConfig)
+
+// WithLogger sets the logger the registry logs to, including a line for each request
+func WithLogger(logger logrus.FieldLogger) ServerOption {
+	return func(config *serverConfig) {
+		config.logger = logger
+	}
+}
+
+// NewServer returns a Server that stores blobs, manifests and tags under root
+func NewServer(root string, opts ...ServerOption) (*Server, error) {
+	config := &serverConfig{
+		logger: logrus.StandardLogger(),
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	// the secret signs upload state between requests, it only has to last as long as the server
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	registryConfig := &configuration.Configuration{
+		Storage: configuration.Storage{
+			"filesystem": configuration.Parameters{"rootdirectory": root},
+			"delete":     configuration.Parameters{"enabled": true},
+			"cache":      configuration.Parameters{"blobdescriptor": "inmemory"},
+			// uploads are only abandoned if a client goes away, so there's nothing to purge in the background
+			"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{"enabled": false}},
+		},
+	}
+	registryConfig.HTTP.Secret = hex.EncodeToString(secret)
+
+	logger := config.logger.WithFields(logrus.Fields{})
+	return &Server{
+		app:    handlers.NewApp(dcontext.WithLogger(context.Background(), logger), registryConfig),
+		logger: logger,
+	}, nil
+}
+
+// ServeHTTP serves the api, logging to the server's logger
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.app.ServeHTTP(w, r.WithContext(dcontext.WithLogger(r.Context(), s.logger)))
+}
@@ -0,0 +1,125 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+
+	"github.com/ecordell/bndlr/pkg/registry/server"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "bndlr-server-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	s, err := server.NewServer(dir, server.WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func do(t *testing.T, method, url, contentType string, body []byte) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// pushImage uploads a config blob and a manifest referencing it, returning the manifest digest
+func pushImage(t *testing.T, srv *httptest.Server, repository, tag string) digest.Digest {
+	t.Helper()
+	config := []byte(`{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`)
+	resp := do(t, http.MethodPost, fmt.Sprintf("%s/v2/%s/blobs/uploads/", srv.URL, repository), "", nil)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("starting upload: %s", resp.Status)
+	}
+	location, err := resp.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := location.Query()
+	query.Set("digest", digest.FromBytes(config).String())
+	location.RawQuery = query.Encode()
+	if resp := do(t, http.MethodPut, location.String(), "application/octet-stream", config); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("uploading config: %s", resp.Status)
+	}
+
+	manifest, err := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     schema2.MediaTypeManifest,
+		"config":        map[string]interface{}{"mediaType": schema2.MediaTypeImageConfig, "digest": digest.FromBytes(config), "size": len(config)},
+		"layers":        []interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = do(t, http.MethodPut, fmt.Sprintf("%s/v2/%s/manifests/%s", srv.URL, repository, tag), schema2.MediaTypeManifest, manifest)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("pushing manifest: %s", resp.Status)
+	}
+	return digest.FromBytes(manifest)
+}
+
+func TestServer(t *testing.T) {
+	srv := newServer(t)
+	d := pushImage(t, srv, "bundles/a", "v1")
+	pushImage(t, srv, "bundles/a", "v2")
+	pushImage(t, srv, "bundles/b", "v1")
+
+	var catalog struct{ Repositories []string }
+	resp := do(t, http.MethodGet, srv.URL+"/v2/_catalog?n=1", "", nil)
+	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(catalog.Repositories, []string{"bundles/a"}) {
+		t.Errorf("expected the first page of the catalog, got %v", catalog.Repositories)
+	}
+	if link := resp.Header.Get("Link"); link != `</v2/_catalog?last=bundles%2Fa&n=1>; rel="next"` {
+		t.Errorf("expected a link to the next page, got %q", link)
+	}
+
+	var tags struct{ Tags []string }
+	if err := json.NewDecoder(do(t, http.MethodGet, srv.URL+"/v2/bundles/a/tags/list", "", nil).Body).Decode(&tags); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tags.Tags, []string{"v1", "v2"}) {
+		t.Errorf("expected tags v1 and v2, got %v", tags.Tags)
+	}
+
+	// deleting is enabled, so images can be removed from a local registry
+	if resp := do(t, http.MethodDelete, fmt.Sprintf("%s/v2/bundles/a/manifests/%s", srv.URL, d), "", nil); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("expected the manifest to be deleted, got %s", resp.Status)
+	}
+	if resp := do(t, http.MethodHead, srv.URL+"/v2/bundles/a/manifests/v1", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected the deleted tag to be gone, got %s", resp.Status)
+	}
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/ecordell/bndlr/pkg/image"
+)
+
+// GetImage reads an image manifest from a content store
+func GetImage(ctx context.Context, provider content.Provider, manifest ocispec.Descriptor) (*image.Descriptor, error) {
+	manifestBytes, err := content.ReadBlob(ctx, provider, manifest)
+	if err != nil {
+		return nil, err
+	}
+	return image.DescriptorFromManifest(manifest, manifestBytes)
+}
+
+// Exists reports whether a blob is in a content store
+func Exists(ctx context.Context, cs content.Manager, d digest.Digest) (bool, error) {
+	_, err := cs.Info(ctx, d)
+	if errdefs.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete removes a blob from a content store, blobs that aren't in the store return an error matching errdefs.IsNotFound
+func Delete(ctx context.Context, cs content.Manager, d digest.Digest) error {
+	exists, err := Exists(ctx, cs, d)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.Wrapf(errdefs.ErrNotFound, "content %v", d)
+	}
+	return cs.Delete(ctx, d)
+}
+
+// GC deletes the blobs of a content store that aren't reachable from the roots, and aborts unfinished writes
+// it returns the deleted blobs, and must not run while the store is written to
+func GC(ctx context.Context, cs content.Store, roots []ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	reachable := map[digest.Digest]bool{}
+	mark := images.HandlerFunc(func(ctx context.Context, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+		reachable[desc.Digest] = true
+		// blobs that were deleted by hand keep what they reference unreachable
+		if exists, err := Exists(ctx, cs, desc.Digest); err != nil || !exists {
+			return nil, err
+		}
+		return images.Children(ctx, cs, desc)
+	})
+	if err := images.Walk(ctx, mark, roots...); err != nil {
+		return nil, err
+	}
+
+	statuses, err := cs.ListStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range statuses {
+		if err := cs.Abort(ctx, status.Ref); err != nil && !errdefs.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	var unreachable []ocispec.Descriptor
+	err = cs.Walk(ctx, func(info content.Info) error {
+		if !reachable[info.Digest] {
+			unreachable = append(unreachable, ocispec.Descriptor{Digest: info.Digest, Size: info.Size})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []ocispec.Descriptor
+	for _, desc := range unreachable {
+		if err := cs.Delete(ctx, desc.Digest); err != nil && !errdefs.IsNotFound(err) {
+			return deleted, err
+		}
+		logrus.WithField("digest", desc.Digest).WithField("size", desc.Size).Debug("deleted unreachable blob")
+		deleted = append(deleted, desc)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/containerd/containerd/images"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// NamedImage is an image a store names by the ref it was built or pulled with
+type NamedImage struct {
+	Ref      string             `json:"ref"`
+	Manifest ocispec.Descriptor `json:"manifest"`
+}
+
+// IsManifest reports whether a media type is an image manifest or index, which stores name by the ref they are written with
+func IsManifest(mediaType string) bool {
+	switch mediaType {
+	case images.MediaTypeDockerSchema2Manifest, images.MediaTypeDockerSchema2ManifestList,
+		ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageIndex:
+		return true
+	}
+	return false
+}
+
+// Names records the manifest each ref names, in memory or in a json file
+type Names struct {
+	mu    sync.Mutex
+	path  string
+	names map[string]ocispec.Descriptor
+}
+
+// NewNames returns names that are only kept in memory
+func NewNames() *Names {
+	return &Names{names: map[string]ocispec.Descriptor{}}
+}
+
+// LoadNames returns names kept in a json file, which is created when a name is first set
+func LoadNames(path string) (*Names, error) {
+	n := &Names{path: path, names: map[string]ocispec.Descriptor{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return n, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &n.names); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Set names a manifest ref, replacing what ref named before
+func (n *Names) Set(ref string, manifest ocispec.Descriptor) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.names[ref] = manifest
+	return n.save()
+}
+
+// Get returns the manifest named ref
+func (n *Names) Get(ref string) (ocispec.Descriptor, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	desc, ok := n.names[ref]
+	return desc, ok
+}
+
+// Delete forgets ref, and reports whether it named anything
+func (n *Names) Delete(ref string) (bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.names[ref]; !ok {
+		return false, nil
+	}
+	delete(n.names, ref)
+	return true, n.save()
+}
+
+// DeleteManifest forgets every ref that names a manifest
+func (n *Names) DeleteManifest(d digest.Digest) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for ref, desc := range n.names {
+		if desc.Digest == d {
+			delete(n.names, ref)
+		}
+	}
+	return n.save()
+}
+
+// List returns the named images, sorted by ref
+func (n *Names) List() []NamedImage {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	named := make([]NamedImage, 0, len(n.names))
+	for ref, desc := range n.names {
+		named = append(named, NamedImage{Ref: ref, Manifest: desc})
+	}
+	sort.Slice(named, func(i, j int) bool { return named[i].Ref < named[j].Ref })
+	return named
+}
+
+// save replaces the names file atomically, names in memory aren't saved
+func (n *Names) save() error {
+	if n.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(n.names, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(n.path), ".names-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), n.path)
+}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/remotes"
+
+	"github.com/ecordell/bndlr/pkg/image"
+)
+
+// ContentStore is the content a store pulls into and pushes from
+type ContentStore interface {
+	content.Ingester
+	content.Provider
+}
+
+// PullImage resolves a ref and fetches its manifest, config and layers into a content store, verifying their digests
+// stores implement Pull with it, providing their own content
+func PullImage(ctx context.Context, resolver remotes.Resolver, ref string, cs ContentStore) (*image.Descriptor, error) {
+	name, desc, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	fetcher, err := resolver.Fetcher(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	handler := images.Handlers(remotes.FetchHandler(cs, fetcher), images.ChildrenHandler(cs))
+	if err := images.Dispatch(ctx, handler, nil, desc); err != nil {
+		return nil, err
+	}
+
+	manifestBytes, err := content.ReadBlob(ctx, cs, desc)
+	if err != nil {
+		return nil, err
+	}
+	return image.DescriptorFromManifest(desc, manifestBytes)
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/remotes"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/ecordell/bndlr/pkg/image"
+	"github.com/ecordell/bndlr/pkg/registry/progress"
+	"github.com/ecordell/bndlr/pkg/registry/retry"
+)
+
+// PushImage pushes the config and layers of an image to ref, and then tags its manifest
+// stores implement Push with it, providing their own content
+func PushImage(ctx context.Context, resolver remotes.Resolver, ref string, provider content.Provider, image *image.Descriptor) (*digest.Digest, error) {
+	if err := PushBlobs(ctx, resolver, ref, provider, image); err != nil {
+		return nil, err
+	}
+	if err := PushManifest(ctx, resolver, ref, provider, image.Manifest); err != nil {
+		return nil, err
+	}
+	return &image.Manifest.Digest, nil
+}
+
+// PushBlobs pushes the config and layers of an image to the repository of ref, without tagging it
+// each blob is retried with the retry policy on the context, and its progress reported to the reporter on the context
+func PushBlobs(ctx context.Context, resolver remotes.Resolver, ref string, provider content.Provider, image *image.Descriptor) error {
+	pusher, err := resolver.Pusher(ctx, ref)
+	if err != nil {
+		return err
+	}
+	handler := pushHandler(ref, pusher, provider)
+	blobs := append([]ocispec.Descriptor{image.Config}, image.Layers...)
+	return images.Dispatch(retry.WithStatusNamespace(ctx, ref), handler, nil, blobs...)
+}
+
+// PushManifest pushes a manifest to ref, whose repository must already have the manifest's blobs
+func PushManifest(ctx context.Context, resolver remotes.Resolver, ref string, provider content.Provider, manifest ocispec.Descriptor) error {
+	pusher, err := resolver.Pusher(ctx, ref)
+	if err != nil {
+		return err
+	}
+	_, err = pushHandler(ref, pusher, provider)(retry.WithStatusNamespace(ctx, ref), manifest)
+	return err
+}
+
+// pushHandler pushes content with retries, reporting progress to the reporter on the context
+func pushHandler(ref string, pusher remotes.Pusher, provider content.Provider) images.HandlerFunc {
+	return progress.Handler(ref, retry.Handler(remotes.PushHandler(pusher, progress.Provider(provider))))
+}
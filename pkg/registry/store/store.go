@@ -2,7 +2,9 @@ package store
 
 import (
 	"context"
+	"io"
 
+	"github.com/containerd/containerd/content"
 	"github.com/containerd/containerd/remotes"
 	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
@@ -10,11 +12,46 @@ import (
 	"github.com/ecordell/bndlr/pkg/image"
 )
 
+// Store is a content-addressed blob store that images are built into, pushed from and pulled into
+// every backend behaves the same, so the store an image is built with never changes what is pushed
 type Store interface {
-	// Write writes a descriptor and blob into the store
-	Write(ctx context.Context, ref string, descriptor ocispec.Descriptor, blob []byte) error
+	// Write streams a blob into the store and returns its descriptor: the expected descriptor, with the digest and size computed as it is written
+	// if the expected descriptor has a digest or size, the written content is verified against them and nothing is stored if it doesn't match
+	// writing content that is already in the store is not an error, and returns the same descriptor
+	Write(ctx context.Context, ref string, expected ocispec.Descriptor, r io.Reader) (ocispec.Descriptor, error)
+
+	// ReaderAt returns a reader for the blob with the digest of the descriptor, its other fields are ignored
+	// blobs that aren't in the store return an error matching errdefs.IsNotFound
+	ReaderAt(ctx context.Context, descriptor ocispec.Descriptor) (content.ReaderAt, error)
 
 	// Push takes a config, a manifest, and a set of layer descriptors and pushes it to the remote
 	// fetching the blobs to push requires knowledge of the backing store, which is why this method is on the Store
 	Push(ctx context.Context, resolver remotes.Resolver, ref string, image *image.Descriptor) (*digest.Digest, error)
+
+	// Pull resolves a ref and fetches its manifest, config, and layers from the remote into the store
+	// digests of fetched blobs are verified as they are written
+	Pull(ctx context.Context, resolver remotes.Resolver, ref string) (*image.Descriptor, error)
+
+	// Get returns the image named ref, manifests written or pulled with a ref are named by it
+	// refs that don't name an image return an error matching errdefs.IsNotFound
+	Get(ctx context.Context, ref string) (*image.Descriptor, error)
+
+	// Exists reports whether the blob with a digest is in the store
+	Exists(ctx context.Context, d digest.Digest) (bool, error)
+
+	// List returns the images the store names, sorted by ref
+	List(ctx context.Context) ([]NamedImage, error)
+
+	// Delete removes a blob, and the refs that name it if it is a manifest
+	Delete(ctx context.Context, d digest.Digest) error
+
+	// Untag forgets ref, the blobs of the image it named are kept until they are garbage collected
+	Untag(ctx context.Context, ref string) error
+
+	// GC deletes the blobs that aren't reachable from a named image, and returns them
+	GC(ctx context.Context) ([]ocispec.Descriptor, error)
+
+	// Close releases the store when it is no longer used, temporary stores delete their content
+	// closing a store more than once is not an error
+	Close() error
 }
@@ -0,0 +1,414 @@
+// Package storetest checks that store.Store implementations follow the same contract
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/ecordell/bndlr/pkg/image"
+	"github.com/ecordell/bndlr/pkg/image/manifest"
+	"github.com/ecordell/bndlr/pkg/registry/store"
+	registrytesting "github.com/ecordell/bndlr/pkg/registry/testing"
+)
+
+// Test runs the store contract against stores made by newStore, every subtest gets a new empty store
+func Test(t *testing.T, newStore func(t *testing.T) store.Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, newStore func(t *testing.T) store.Store)
+	}{
+		{"write computes descriptor", testWriteComputesDescriptor},
+		{"write verifies digest", testWriteVerifiesDigest},
+		{"write verifies size", testWriteVerifiesSize},
+		{"write existing content", testWriteExisting},
+		{"write existing content verifies", testWriteExistingVerifies},
+		{"write after failed write with same ref", testWriteAfterFailure},
+		{"read by digest", testReadByDigest},
+		{"read missing content", testReadMissing},
+		{"get named image", testGet},
+		{"get unknown ref", testGetUnknown},
+		{"exists", testExists},
+		{"delete", testDelete},
+		{"untag", testUntag},
+		{"gc", testGC},
+		{"push and pull", testPushPull},
+		{"close", testClose},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore)
+		})
+	}
+}
+
+var blob = []byte("the same content, whatever the store")
+
+func write(t *testing.T, s store.Store, expected ocispec.Descriptor, data []byte) ocispec.Descriptor {
+	t.Helper()
+	return writeRef(t, s, "test", expected, data)
+}
+
+func writeRef(t *testing.T, s store.Store, ref string, expected ocispec.Descriptor, data []byte) ocispec.Descriptor {
+	t.Helper()
+	desc, err := s.Write(context.Background(), ref, expected, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return desc
+}
+
+func read(t *testing.T, s store.Store, desc ocispec.Descriptor) []byte {
+	t.Helper()
+	data, err := content.ReadBlob(context.Background(), s, desc)
+	if err != nil {
+		t.Fatalf("read %s: %v", desc.Digest, err)
+	}
+	return data
+}
+
+func assertMissing(t *testing.T, s store.Store, d digest.Digest) {
+	t.Helper()
+	if _, err := s.ReaderAt(context.Background(), ocispec.Descriptor{Digest: d}); !errdefs.IsNotFound(err) {
+		t.Errorf("expected %s to be missing, got error %v", d, err)
+	}
+}
+
+func testWriteComputesDescriptor(t *testing.T, newStore func(t *testing.T) store.Store) {
+	s := newStore(t)
+	expected := ocispec.Descriptor{
+		MediaType:   ocispec.MediaTypeImageLayerGzip,
+		Annotations: map[string]string{"key": "value"},
+	}
+	desc := write(t, s, expected, blob)
+
+	if desc.Digest != digest.FromBytes(blob) {
+		t.Errorf("digest %s, expected %s", desc.Digest, digest.FromBytes(blob))
+	}
+	if desc.Size != int64(len(blob)) {
+		t.Errorf("size %d, expected %d", desc.Size, len(blob))
+	}
+	if desc.MediaType != expected.MediaType {
+		t.Errorf("media type %q, expected %q", desc.MediaType, expected.MediaType)
+	}
+	if desc.Annotations["key"] != "value" {
+		t.Errorf("annotations %v weren't kept", desc.Annotations)
+	}
+}
+
+func testWriteVerifiesDigest(t *testing.T, newStore func(t *testing.T) store.Store) {
+	s := newStore(t)
+	wrong := digest.FromString("something else")
+	if _, err := s.Write(context.Background(), "test", ocispec.Descriptor{Digest: wrong}, bytes.NewReader(blob)); err == nil {
+		t.Fatal("expected content that doesn't match its digest to fail")
+	}
+	assertMissing(t, s, wrong)
+	assertMissing(t, s, digest.FromBytes(blob))
+}
+
+func testWriteVerifiesSize(t *testing.T, newStore func(t *testing.T) store.Store) {
+	s := newStore(t)
+	if _, err := s.Write(context.Background(), "test", ocispec.Descriptor{Size: 1}, bytes.NewReader(blob)); err == nil {
+		t.Fatal("expected content that doesn't match its size to fail")
+	}
+	assertMissing(t, s, digest.FromBytes(blob))
+}
+
+func testWriteExisting(t *testing.T, newStore func(t *testing.T) store.Store) {
+	s := newStore(t)
+	first := write(t, s, ocispec.Descriptor{MediaType: ocispec.MediaTypeImageLayer}, blob)
+	second := write(t, s, ocispec.Descriptor{MediaType: ocispec.MediaTypeImageLayer, Digest: first.Digest}, blob)
+	if second.Digest != first.Digest || second.Size != first.Size || second.MediaType != first.MediaType {
+		t.Errorf("writing existing content returned %+v, first write returned %+v", second, first)
+	}
+	if !bytes.Equal(read(t, s, first), blob) {
+		t.Error("content changed when it was written again")
+	}
+}
+
+func testWriteExistingVerifies(t *testing.T, newStore func(t *testing.T) store.Store) {
+	s := newStore(t)
+	first := write(t, s, ocispec.Descriptor{}, blob)
+	if _, err := s.Write(context.Background(), "test", ocispec.Descriptor{Digest: first.Digest}, bytes.NewReader([]byte("different"))); err == nil {
+		t.Fatal("expected different content with the digest of existing content to fail")
+	}
+	if !bytes.Equal(read(t, s, first), blob) {
+		t.Error("existing content changed")
+	}
+}
+
+// failingReader returns an error after its content
+type failingReader struct {
+	r io.Reader
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+func testWriteAfterFailure(t *testing.T, newStore func(t *testing.T) store.Store) {
+	s := newStore(t)
+	partial := &failingReader{r: bytes.NewReader(blob[:len(blob)/2])}
+	if _, err := s.Write(context.Background(), "test", ocispec.Descriptor{}, partial); err == nil {
+		t.Fatal("expected a failed read to fail the write")
+	}
+	assertMissing(t, s, digest.FromBytes(blob[:len(blob)/2]))
+
+	desc := write(t, s, ocispec.Descriptor{}, blob)
+	if desc.Digest != digest.FromBytes(blob) {
+		t.Errorf("digest %s, expected %s, content from the failed write was kept", desc.Digest, digest.FromBytes(blob))
+	}
+	if !bytes.Equal(read(t, s, desc), blob) {
+		t.Error("content from the failed write was kept")
+	}
+}
+
+func testReadByDigest(t *testing.T, newStore func(t *testing.T) store.Store) {
+	s := newStore(t)
+	desc := write(t, s, ocispec.Descriptor{MediaType: ocispec.MediaTypeImageLayer}, blob)
+
+	ra, err := s.ReaderAt(context.Background(), ocispec.Descriptor{Digest: desc.Digest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ra.Close()
+	if ra.Size() != desc.Size {
+		t.Errorf("size %d, expected %d", ra.Size(), desc.Size)
+	}
+	data := make([]byte, ra.Size())
+	if _, err := ra.ReadAt(data, 0); err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, blob) {
+		t.Error("read different content than was written")
+	}
+}
+
+func testReadMissing(t *testing.T, newStore func(t *testing.T) store.Store) {
+	assertMissing(t, newStore(t), digest.FromBytes(blob))
+}
+
+// writeImage writes an image with a layer of data into the store, named ref
+func writeImage(t *testing.T, s store.Store, ref string, data []byte) *image.Descriptor {
+	t.Helper()
+	layer := writeRef(t, s, ref, ocispec.Descriptor{MediaType: images.MediaTypeDockerSchema2LayerGzip}, data)
+	configBytes, config, err := manifest.NewMinimalV22Config([]digest.Digest{layer.Digest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config = writeRef(t, s, ref, config, configBytes)
+	manifestBytes, manifestDesc, err := manifest.NewV22Manifest(config, []ocispec.Descriptor{layer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestDesc = writeRef(t, s, ref, manifestDesc, manifestBytes)
+	return &image.Descriptor{Manifest: manifestDesc, Config: config, Layers: []ocispec.Descriptor{layer}}
+}
+
+func assertExists(t *testing.T, s store.Store, d digest.Digest, want bool) {
+	t.Helper()
+	exists, err := s.Exists(context.Background(), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists != want {
+		t.Errorf("%s exists = %v, expected %v", d, exists, want)
+	}
+}
+
+func list(t *testing.T, s store.Store) []store.NamedImage {
+	t.Helper()
+	named, err := s.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return named
+}
+
+func testGet(t *testing.T, newStore func(t *testing.T) store.Store) {
+	s := newStore(t)
+	written := writeImage(t, s, "example.com/bundle:v1", blob)
+
+	got, err := s.Get(context.Background(), "example.com/bundle:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Manifest.Digest != written.Manifest.Digest || got.Config.Digest != written.Config.Digest ||
+		len(got.Layers) != 1 || got.Layers[0].Digest != written.Layers[0].Digest {
+		t.Errorf("got %+v, expected %+v", got, written)
+	}
+
+	named := list(t, s)
+	if len(named) != 1 || named[0].Ref != "example.com/bundle:v1" || named[0].Manifest.Digest != written.Manifest.Digest {
+		t.Errorf("listed %+v, expected only example.com/bundle:v1 naming %s", named, written.Manifest.Digest)
+	}
+}
+
+func testGetUnknown(t *testing.T, newStore func(t *testing.T) store.Store) {
+	if _, err := newStore(t).Get(context.Background(), "example.com/bundle:missing"); !errdefs.IsNotFound(err) {
+		t.Errorf("expected an unknown ref to be not found, got error %v", err)
+	}
+}
+
+func testExists(t *testing.T, newStore func(t *testing.T) store.Store) {
+	s := newStore(t)
+	assertExists(t, s, digest.FromBytes(blob), false)
+	write(t, s, ocispec.Descriptor{}, blob)
+	assertExists(t, s, digest.FromBytes(blob), true)
+}
+
+func testDelete(t *testing.T, newStore func(t *testing.T) store.Store) {
+	s := newStore(t)
+	img := writeImage(t, s, "example.com/bundle:v1", blob)
+
+	if err := s.Delete(context.Background(), img.Layers[0].Digest); err != nil {
+		t.Fatal(err)
+	}
+	assertExists(t, s, img.Layers[0].Digest, false)
+	assertMissing(t, s, img.Layers[0].Digest)
+	if len(list(t, s)) != 1 {
+		t.Error("deleting a layer forgot the image")
+	}
+
+	if err := s.Delete(context.Background(), img.Manifest.Digest); err != nil {
+		t.Fatal(err)
+	}
+	if named := list(t, s); len(named) != 0 {
+		t.Errorf("deleting a manifest kept its names %+v", named)
+	}
+	if err := s.Delete(context.Background(), img.Manifest.Digest); !errdefs.IsNotFound(err) {
+		t.Errorf("expected deleting missing content to be not found, got error %v", err)
+	}
+}
+
+func testUntag(t *testing.T, newStore func(t *testing.T) store.Store) {
+	s := newStore(t)
+	img := writeImage(t, s, "example.com/bundle:v1", blob)
+
+	if err := s.Untag(context.Background(), "example.com/bundle:v1"); err != nil {
+		t.Fatal(err)
+	}
+	if named := list(t, s); len(named) != 0 {
+		t.Errorf("listed %+v after untagging", named)
+	}
+	assertExists(t, s, img.Manifest.Digest, true)
+	if err := s.Untag(context.Background(), "example.com/bundle:v1"); !errdefs.IsNotFound(err) {
+		t.Errorf("expected untagging an unknown ref to be not found, got error %v", err)
+	}
+}
+
+func testGC(t *testing.T, newStore func(t *testing.T) store.Store) {
+	ctx := context.Background()
+	s := newStore(t)
+	kept := writeImage(t, s, "example.com/bundle:v1", blob)
+	untagged := writeImage(t, s, "example.com/bundle:v2", []byte("a layer only v2 has"))
+	orphan := write(t, s, ocispec.Descriptor{}, []byte("a blob no image references"))
+	if err := s.Untag(ctx, "example.com/bundle:v2"); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := s.GC(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[digest.Digest]bool{
+		untagged.Manifest.Digest:  true,
+		untagged.Config.Digest:    true,
+		untagged.Layers[0].Digest: true,
+		orphan.Digest:             true,
+	}
+	for _, desc := range deleted {
+		if !want[desc.Digest] {
+			t.Errorf("deleted %s, which is reachable", desc.Digest)
+		}
+		delete(want, desc.Digest)
+	}
+	for d := range want {
+		t.Errorf("kept %s, which is unreachable", d)
+	}
+	for _, desc := range []ocispec.Descriptor{kept.Manifest, kept.Config, kept.Layers[0]} {
+		assertExists(t, s, desc.Digest, true)
+	}
+	if _, err := s.Get(ctx, "example.com/bundle:v1"); err != nil {
+		t.Errorf("image is unreadable after gc: %v", err)
+	}
+
+	if deleted, err := s.GC(ctx); err != nil || len(deleted) != 0 {
+		t.Errorf("second gc deleted %+v, error %v", deleted, err)
+	}
+}
+
+func testPushPull(t *testing.T, newStore func(t *testing.T) store.Store) {
+	ctx := context.Background()
+	r, err := registrytesting.NewRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	resolver, err := r.Resolver("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := r.Ref("store", "test")
+
+	s := newStore(t)
+	img := writeImage(t, s, "test", blob)
+
+	pushed, err := s.Push(ctx, resolver, ref, img)
+	if err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if *pushed != img.Manifest.Digest {
+		t.Errorf("pushed %s, expected %s", *pushed, img.Manifest.Digest)
+	}
+
+	pulledStore := newStore(t)
+	pulled, err := pulledStore.Pull(ctx, resolver, ref)
+	if err != nil {
+		t.Fatalf("pull: %v", err)
+	}
+	if pulled.Manifest.Digest != img.Manifest.Digest {
+		t.Errorf("pulled %s, expected %s", pulled.Manifest.Digest, img.Manifest.Digest)
+	}
+	if len(pulled.Layers) != 1 || pulled.Layers[0].Digest != img.Layers[0].Digest || pulled.Config.Digest != img.Config.Digest {
+		t.Fatalf("pulled %+v, expected %+v", pulled, img)
+	}
+	for _, desc := range []ocispec.Descriptor{pulled.Manifest, pulled.Config, pulled.Layers[0]} {
+		if !bytes.Equal(read(t, pulledStore, desc), read(t, s, desc)) {
+			t.Errorf("pulled different content for %s", desc.Digest)
+		}
+	}
+
+	// pulled images are named by the ref they were pulled with
+	named, err := pulledStore.Get(ctx, ref)
+	if err != nil {
+		t.Fatalf("get pulled image: %v", err)
+	}
+	if named.Manifest.Digest != img.Manifest.Digest {
+		t.Errorf("pulled image is named %s, expected %s", named.Manifest.Digest, img.Manifest.Digest)
+	}
+}
+
+func testClose(t *testing.T, newStore func(t *testing.T) store.Store) {
+	s := newStore(t)
+	writeImage(t, s, "example.com/bundle:v1", blob)
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("expected closing twice to succeed, got error %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// WriteContent streams a blob into a content ingester, computing its digest and size as it is written
+// content that already exists in the ingester is not an error, it is still read to verify it and complete the descriptor
+func WriteContent(ctx context.Context, ingester content.Ingester, ref string, expected ocispec.Descriptor, r io.Reader) (ocispec.Descriptor, error) {
+	writer, err := content.OpenWriter(ctx, ingester, content.WithRef(ref), content.WithDescriptor(expected))
+	if errdefs.IsAlreadyExists(err) {
+		return digestContent(expected, r)
+	}
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	defer writer.Close()
+
+	// discard anything left over from an interrupted write with the same ref
+	if err := writer.Truncate(0); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	size, err := io.Copy(writer, r)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	desc := expected
+	desc.Digest = writer.Digest()
+	desc.Size = size
+	if err := Verify(expected, desc); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	if err := writer.Commit(ctx, size, desc.Digest); err != nil && !errdefs.IsAlreadyExists(err) {
+		return ocispec.Descriptor{}, err
+	}
+	return desc, nil
+}
+
+// digestContent computes the descriptor of content without storing it
+func digestContent(expected ocispec.Descriptor, r io.Reader) (ocispec.Descriptor, error) {
+	digester := digest.Canonical.Digester()
+	size, err := io.Copy(digester.Hash(), r)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	desc := expected
+	desc.Digest = digester.Digest()
+	desc.Size = size
+	if err := Verify(expected, desc); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	return desc, nil
+}
+
+// Verify checks that written content matches the digest and size of the expected descriptor, if they are set
+func Verify(expected, written ocispec.Descriptor) error {
+	if expected.Digest != "" && expected.Digest != written.Digest {
+		return fmt.Errorf("unexpected digest %s, expected %s", written.Digest, expected.Digest)
+	}
+	if expected.Size != 0 && expected.Size != written.Size {
+		return fmt.Errorf("unexpected size %d for %s, expected %d", written.Size, written.Digest, expected.Size)
+	}
+	return nil
+}
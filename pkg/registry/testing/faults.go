@@ -0,0 +1,60 @@
+package testing
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// Fault makes the registry fail requests instead of serving them
+type Fault struct {
+	// Method matches the request method, every method if empty
+	Method string
+	// Path is a regular expression matched against the request path, i.e. `/blobs/uploads/`
+	Path string
+	// Status is returned without serving the request
+	Status int
+	// Corrupt serves the request but flips the first byte of the response body, instead of returning Status
+	Corrupt bool
+	// Times is the number of matching requests that fail, every matching request if 0
+	Times int
+}
+
+type fault struct {
+	Fault
+	path      *regexp.Regexp
+	remaining int
+}
+
+// Inject adds a fault, faults are matched in the order they were added
+// auth is checked before faults, so requests that are challenged don't count towards Times
+func (r *Registry) Inject(f Fault) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.faults = append(r.faults, &fault{
+		Fault:     f,
+		path:      regexp.MustCompile(f.Path),
+		remaining: f.Times,
+	})
+}
+
+// fault returns the fault to apply to a request, if any
+func (r *Registry) fault(req *http.Request) *fault {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.faults {
+		if f.Method != "" && f.Method != req.Method {
+			continue
+		}
+		if !f.path.MatchString(req.URL.Path) {
+			continue
+		}
+		if f.Times > 0 {
+			if f.remaining == 0 {
+				continue
+			}
+			f.remaining--
+		}
+		return f
+	}
+	return nil
+}
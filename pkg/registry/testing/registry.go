@@ -0,0 +1,267 @@
+// Package testing runs in-process registries for tests, with optional auth and fault injection
+package testing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/containerd/containerd/remotes"
+	"github.com/sirupsen/logrus"
+
+	"github.com/ecordell/bndlr/pkg/registry"
+	"github.com/ecordell/bndlr/pkg/registry/server"
+)
+
+// Registry is a registry served by server.Server over a temporary directory, on a local port
+type Registry struct {
+	server *httptest.Server
+	dir    string
+	config registryConfig
+	token  string
+
+	mu       sync.Mutex
+	faults   []*fault
+	requests []Request
+}
+
+// Request is a request the registry received, with the status it responded with
+type Request struct {
+	Method string
+	Path   string
+	Status int
+}
+
+type auth int
+
+const (
+	noAuth auth = iota
+	basicAuth
+	tokenAuth
+)
+
+type registryConfig struct {
+	auth     auth
+	username string
+	password string
+}
+
+// RegistryOption configures a Registry
+type RegistryOption func(config *registryConfig)
+
+// WithBasicAuth requires every request to have basic auth with username and password
+func WithBasicAuth(username, password string) RegistryOption {
+	return func(config *registryConfig) {
+		config.auth = basicAuth
+		config.username = username
+		config.password = password
+	}
+}
+
+// WithTokenAuth requires every request to have a bearer token, which the registry issues at /token for username and password
+// tokens are requested with basic auth or with an oauth password grant
+func WithTokenAuth(username, password string) RegistryOption {
+	return func(config *registryConfig) {
+		config.auth = tokenAuth
+		config.username = username
+		config.password = password
+	}
+}
+
+// NewRegistry starts a registry, which must be stopped with Close
+func NewRegistry(opts ...RegistryOption) (*Registry, error) {
+	r := &Registry{}
+	for _, opt := range opts {
+		opt(&r.config)
+	}
+
+	dir, err := ioutil.TempDir("", "bndlr-registry-")
+	if err != nil {
+		return nil, err
+	}
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	handler, err := server.NewServer(filepath.Join(dir, "root"), server.WithLogger(logger))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	if r.token, err = randomString(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	r.dir = dir
+	r.server = httptest.NewServer(r.handler(handler))
+	return r, nil
+}
+
+// Close stops the registry and removes its content
+func (r *Registry) Close() error {
+	r.server.Close()
+	return os.RemoveAll(r.dir)
+}
+
+// Host returns the host and port of the registry, i.e. 127.0.0.1:34567
+func (r *Registry) Host() string {
+	return strings.TrimPrefix(r.server.URL, "http://")
+}
+
+// Ref returns a reference to a tag of a repository on the registry
+func (r *Registry) Ref(repository, tag string) string {
+	return fmt.Sprintf("%s/%s:%s", r.Host(), repository, tag)
+}
+
+// Resolver returns a resolver for the registry that authenticates with username and password, if set
+// auth files in the environment are never read
+func (r *Registry) Resolver(username, password string, opts ...registry.ResolverOption) (remotes.Resolver, error) {
+	return registry.NewResolver(username, password, []string{filepath.Join(r.dir, "auth.json")}, opts...)
+}
+
+// Requests returns the requests the registry has received, in order
+func (r *Registry) Requests() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Request(nil), r.requests...)
+}
+
+// Count returns the number of requests with a method, or any method if empty, whose path matches a regular expression
+func (r *Registry) Count(method, path string) int {
+	re := regexp.MustCompile(path)
+	count := 0
+	for _, req := range r.Requests() {
+		if (method == "" || req.Method == method) && re.MatchString(req.Path) {
+			count++
+		}
+	}
+	return count
+}
+
+// Reset forgets the requests received so far and removes any faults
+func (r *Registry) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = nil
+	r.faults = nil
+}
+
+func (r *Registry) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rw := &recorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			r.mu.Lock()
+			r.requests = append(r.requests, Request{Method: req.Method, Path: req.URL.Path, Status: rw.status})
+			r.mu.Unlock()
+		}()
+
+		if req.URL.Path == "/token" {
+			r.serveToken(rw, req)
+			return
+		}
+		if !r.authorized(req) {
+			r.challenge(rw, req)
+			return
+		}
+		if f := r.fault(req); f != nil {
+			if !f.Corrupt {
+				rw.WriteHeader(f.Status)
+				return
+			}
+			rw.corrupt = true
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+func (r *Registry) authorized(req *http.Request) bool {
+	switch r.config.auth {
+	case basicAuth:
+		username, password, ok := req.BasicAuth()
+		return ok && username == r.config.username && password == r.config.password
+	case tokenAuth:
+		return req.Header.Get("Authorization") == "Bearer "+r.token
+	}
+	return true
+}
+
+// repositoryRegexp matches the repository of a request, to scope token challenges to it
+var repositoryRegexp = regexp.MustCompile(`^/v2/(.+)/(?:blobs|manifests|tags)/`)
+
+func (r *Registry) challenge(w http.ResponseWriter, req *http.Request) {
+	switch r.config.auth {
+	case basicAuth:
+		w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+	case tokenAuth:
+		scope := "registry:catalog:*"
+		if match := repositoryRegexp.FindStringSubmatch(req.URL.Path); match != nil {
+			scope = "repository:" + match[1] + ":pull,push"
+		}
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="%s"`, r.server.URL, scope))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write([]byte(`{"errors":[{"code":"UNAUTHORIZED","message":"authentication required"}]}`))
+}
+
+// serveToken issues the registry's token for valid credentials
+func (r *Registry) serveToken(w http.ResponseWriter, req *http.Request) {
+	if r.config.auth != tokenAuth {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	username, password, ok := req.BasicAuth()
+	if req.Method == http.MethodPost {
+		if err := req.ParseForm(); err == nil && req.PostForm.Get("grant_type") == "password" {
+			username, password, ok = req.PostForm.Get("username"), req.PostForm.Get("password"), true
+		}
+	}
+	if !ok || username != r.config.username || password != r.config.password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":        r.token,
+		"access_token": r.token,
+		"expires_in":   300,
+	})
+}
+
+// recorder records the status of a response, and corrupts its body if asked to
+type recorder struct {
+	http.ResponseWriter
+	status  int
+	corrupt bool
+}
+
+func (w *recorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recorder) Write(p []byte) (int, error) {
+	if w.corrupt && len(p) > 0 {
+		corrupted := append([]byte(nil), p...)
+		corrupted[0] ^= 0xff
+		w.corrupt = false
+		return w.ResponseWriter.Write(corrupted)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -13,10 +13,16 @@ var (
 	signalCtx       context.Context
 	cancel          context.CancelFunc
 	once            sync.Once
+
+	cleanupMu sync.Mutex
+	cleanups  []func()
+
+	// exit is replaced in tests
+	exit = os.Exit
 )
 
 // Context returns a Context registered to close on SIGTERM and SIGINT.
-// If a second signal is caught, the program is terminated with exit code 1.
+// If a second signal is caught, the program is terminated with exit code 1, after running the OnForcedExit funcs.
 func Context() context.Context {
 	once.Do(func() {
 		c := make(chan os.Signal, 2)
@@ -26,13 +32,27 @@ func Context() context.Context {
 			<-c
 			cancel()
 
-			select {
-			case <-signalCtx.Done():
-			case <-c:
-				os.Exit(1) // second signal. Exit directly.
-			}
+			<-c
+			runCleanups()
+			exit(1) // second signal. Exit directly.
 		}()
 	})
 
 	return signalCtx
 }
+
+// OnForcedExit registers fn to run if a second signal terminates the program before deferred cleanup can run.
+// fn may run concurrently with the rest of the program, so it must be safe to call at any time.
+func OnForcedExit(fn func()) {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	cleanups = append(cleanups, fn)
+}
+
+func runCleanups() {
+	cleanupMu.Lock()
+	defer cleanupMu.Unlock()
+	for _, fn := range cleanups {
+		fn()
+	}
+}
@@ -0,0 +1,53 @@
+package signals
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSecondSignalRunsCleanupsAndExits(t *testing.T) {
+	once, cleanups = sync.Once{}, nil
+	exited := make(chan int, 1)
+	exit = func(code int) { exited <- code }
+	defer func() { exit = os.Exit }()
+
+	cleaned := make(chan struct{}, 1)
+	OnForcedExit(func() { cleaned <- struct{}{} })
+
+	ctx := Context()
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGINT); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context wasn't cancelled by the first signal")
+	}
+	select {
+	case <-cleaned:
+		t.Fatal("cleanups ran after the first signal")
+	case code := <-exited:
+		t.Fatalf("exited with %d after the first signal", code)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case code := <-exited:
+		if code != 1 {
+			t.Errorf("expected exit code 1, got %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second signal didn't exit")
+	}
+	select {
+	case <-cleaned:
+	default:
+		t.Error("cleanups didn't run before exiting")
+	}
+}